@@ -0,0 +1,77 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+
+// GraphCommand renders the state machine of a config as a diagram
+var GraphCommand = &cobra.Command{
+	Use:   "graph",
+	Short: "Render the script in a config-file as a DOT or Mermaid diagram",
+	RunE:  runGraphCommand,
+}
+
+func init() {
+	RootCommand.AddCommand(GraphCommand)
+
+	GraphCommand.
+		PersistentFlags().
+		StringVarP(
+			&configFile,
+			"config-file",
+			"c",
+			"",
+			"Path to config file",
+		)
+
+	GraphCommand.
+		PersistentFlags().
+		StringVarP(
+			&graphFormat,
+			"format",
+			"f",
+			"dot",
+			"Format of the diagram, dot or mermaid",
+		)
+}
+
+func runGraphCommand(
+	cmd *cobra.Command,
+	args []string,
+) (
+	err error,
+) {
+	config, err := loadConfig(
+		configFile,
+	)
+	if err != nil {
+		return
+	}
+
+	if config.Script == nil {
+		err = fmt.Errorf("no script in %s", configFile)
+		return
+	}
+
+	switch graphFormat {
+	case "dot":
+		err = runner.WriteDot(os.Stdout, config.Script)
+
+	case "mermaid":
+		err = runner.WriteMermaid(os.Stdout, config.Script)
+
+	default:
+		err = fmt.Errorf("unknown format %q", graphFormat)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/ghodss/yaml"
 
-	"github.com/Gameye/igniter-shell-go/runner"
 	"github.com/Gameye/igniter-shell-go/utils"
 
 	"github.com/Gameye/igniter-shell-go/shell"
@@ -19,6 +18,9 @@ import (
 
 var emulateTTY bool
 var configFile string
+var eventSink string
+var apiAddr string
+var initMode bool
 var variableList *[]string
 
 // LaunchCommand launches a process
@@ -41,6 +43,16 @@ func init() {
 			"Emulate a TTY for the child process",
 		)
 
+	LaunchCommand.
+		PersistentFlags().
+		BoolVarP(
+			&initMode,
+			"init",
+			"i",
+			false,
+			"Behave like an init process, reap zombies and signal the whole process group",
+		)
+
 	LaunchCommand.
 		PersistentFlags().
 		StringVarP(
@@ -51,6 +63,26 @@ func init() {
 			"Path to config file",
 		)
 
+	LaunchCommand.
+		PersistentFlags().
+		StringVarP(
+			&eventSink,
+			"event-sink",
+			"e",
+			"",
+			"Where to write state changes as json lines. \nOne of fd:<number>, file:<path> or unix:<path>",
+		)
+
+	LaunchCommand.
+		PersistentFlags().
+		StringVarP(
+			&apiAddr,
+			"api-addr",
+			"a",
+			"",
+			"Address for the local http status and control api, e.g. 127.0.0.1:8080",
+		)
+
 	variableList = LaunchCommand.
 		PersistentFlags().
 		StringArrayP(
@@ -75,18 +107,9 @@ func runLaunchCommand(
 		return
 	}
 
-	variables := make(map[string]string)
-	for key, value := range config.Defaults {
-		variables[key] = value
-	}
-	for _, variableItem := range *variableList {
-		pair := strings.SplitN(variableItem, "=", 2)
-		variables[pair[0]] = pair[1]
-	}
-
 	renderConfigTemplate(
 		config,
-		variables,
+		makeVariables(config, *variableList),
 	)
 
 	for _, file := range config.Files {
@@ -101,12 +124,31 @@ func runLaunchCommand(
 		commandArgs[0],
 		commandArgs[1:]...,
 	)
-	proc.Env = os.Environ()
+	proc.Env = shell.MakeEnviron(os.Environ(), config)
+
+	options := shell.Options{
+		APIAddr: apiAddr,
+		Signals: config.Signals,
+		Init:    initMode,
+		RCON:    config.RCON,
+		Sources: config.Sources,
+		Restart: config.Restart,
+		Result:  config.Result,
+	}
+	if eventSink != "" {
+		sink, err := shell.OpenEventSink(eventSink)
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+		options.Events = sink
+	}
 
 	exit, err := shell.RunWithRunner(
 		proc,
 		config.Script,
 		emulateTTY,
+		options,
 	)
 	if err != nil {
 		return
@@ -117,6 +159,28 @@ func runLaunchCommand(
 	return
 }
 
+/*
+makeVariables makes variables from the defaults in the config and variable
+items in the key=value format
+*/
+func makeVariables(
+	config *shell.Config,
+	variableItems []string,
+) (
+	variables map[string]string,
+) {
+	variables = make(map[string]string)
+	for key, value := range config.Defaults {
+		variables[key] = value
+	}
+	for _, variableItem := range variableItems {
+		pair := strings.SplitN(variableItem, "=", 2)
+		variables[pair[0]] = pair[1]
+	}
+
+	return
+}
+
 func renderConfigTemplate(
 	config *shell.Config,
 	variables map[string]string,
@@ -142,16 +206,51 @@ func renderConfigTemplate(
 		)
 	}
 
-	for index, transitionConfigUnknown := range config.Script.Transitions {
-		switch transitionConfig := transitionConfigUnknown.(type) {
-		case runner.CommandTransitionConfig:
-			transitionConfig.Command = utils.RenderTemplate(
-				transitionConfig.Command,
+	if config.RCON != nil {
+		config.RCON.Address = utils.RenderTemplate(
+			config.RCON.Address,
+			variables,
+		)
+		config.RCON.Password = utils.RenderTemplate(
+			config.RCON.Password,
+			variables,
+		)
+	}
+
+	for index, sourceConfigUnknown := range config.Sources {
+		switch sourceConfig := sourceConfigUnknown.(type) {
+		case shell.FileSourceConfig:
+			sourceConfig.Path = utils.RenderTemplate(
+				sourceConfig.Path,
+				variables,
+			)
+			config.Sources[index] = sourceConfig
+
+		case shell.UDPSourceConfig:
+			sourceConfig.Address = utils.RenderTemplate(
+				sourceConfig.Address,
+				variables,
+			)
+			sourceConfig.Secret = utils.RenderTemplate(
+				sourceConfig.Secret,
 				variables,
 			)
-			config.Script.Transitions[index] = transitionConfig
+			config.Sources[index] = sourceConfig
 		}
 	}
+
+	if config.Result != nil {
+		config.Result.Path = utils.RenderTemplate(
+			config.Result.Path,
+			variables,
+		)
+	}
+
+	/*
+		transitions are rendered once, when they happen, and conditions are
+		evaluated with the variables while running
+	*/
+	config.Script.Variables = variables
 }
 
 func loadConfig(
@@ -167,6 +266,9 @@ func loadConfig(
 	}
 
 	jsonData, err := yaml.YAMLToJSON(yamlData)
+	if err != nil {
+		return
+	}
 
 	err = json.Unmarshal(jsonData, config)
 	if err != nil {
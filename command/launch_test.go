@@ -0,0 +1,55 @@
+package command
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/Gameye/igniter-shell-go/shell"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderConfigTemplate(test *testing.T) {
+	config := &shell.Config{
+		Script: &runner.Config{
+			InitialState: "playing",
+			States: runner.StateConfigMap{
+				"playing": runner.StateConfig{
+					Events: runner.EventConfigList{
+						runner.RegexEventConfig{
+							Regexp:    regexp.MustCompile(`^Team (?P<winner>\w+) won$`),
+							NextState: "quit",
+						},
+					},
+				},
+			},
+			Transitions: runner.TransitionConfigList{
+				runner.CommandTransitionConfig{
+					To:      "quit",
+					Command: "say ${motd}, ${winner} won",
+				},
+			},
+		},
+	}
+
+	// a launch variable that looks like a variable is not replaced again
+	renderConfigTemplate(config, map[string]string{
+		"motd": "${winner} rules",
+	})
+
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+	stateChanges := runner.Simulate(
+		config.Script,
+		start,
+		[]runner.TimedLine{
+			{Line: "Team red won", Time: start},
+		},
+		start,
+	)
+	if assert.Len(test, stateChanges, 1) {
+		assert.Equal(test, []runner.Action{
+			runner.CommandAction{Command: "say ${winner} rules, red won"},
+		}, stateChanges[0].Actions)
+	}
+}
@@ -0,0 +1,313 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/Gameye/igniter-shell-go/utils"
+	"github.com/spf13/cobra"
+)
+
+var logFile string
+var timePattern string
+var timeLayout string
+var linePace float64
+var runAfter float64
+var simulateVariableList *[]string
+
+// SimulateCommand replays a log against a config
+var SimulateCommand = &cobra.Command{
+	Use:   "simulate",
+	Short: "Replay a captured server log against the script in a config-file",
+	RunE:  runSimulateCommand,
+}
+
+func init() {
+	RootCommand.AddCommand(SimulateCommand)
+
+	SimulateCommand.
+		PersistentFlags().
+		StringVarP(
+			&configFile,
+			"config-file",
+			"c",
+			"",
+			"Path to config file",
+		)
+
+	SimulateCommand.
+		PersistentFlags().
+		StringVarP(
+			&logFile,
+			"log",
+			"l",
+			"-",
+			"Path to the log file to replay, - for stdin",
+		)
+
+	SimulateCommand.
+		PersistentFlags().
+		StringVar(
+			&timePattern,
+			"time-pattern",
+			"",
+			"Regular expression that finds the timestamp in a line, the first group (or the whole match) is the timestamp. \nE.g. '^L (\\S+ - \\S+):' for source engine logs",
+		)
+
+	SimulateCommand.
+		PersistentFlags().
+		StringVar(
+			&timeLayout,
+			"time-layout",
+			"",
+			"Layout of the timestamps in the go time format. \nE.g. '01/02/2006 - 15:04:05' for source engine logs",
+		)
+
+	SimulateCommand.
+		PersistentFlags().
+		Float64Var(
+			&linePace,
+			"pace",
+			0,
+			"Milliseconds that pass for every line without a timestamp",
+		)
+
+	SimulateCommand.
+		PersistentFlags().
+		Float64Var(
+			&runAfter,
+			"run-after",
+			3600000,
+			"Milliseconds to keep the clock running after the last line, so timers can fire",
+		)
+
+	simulateVariableList = SimulateCommand.
+		PersistentFlags().
+		StringArrayP(
+			"variable",
+			"v",
+			[]string{},
+			"The variables which should be replaced in the config. \nCan be passed multiple times for multiple variables. \nEach variable should have the format key=value",
+		)
+}
+
+func runSimulateCommand(
+	cmd *cobra.Command,
+	args []string,
+) (
+	err error,
+) {
+	config, err := loadConfig(
+		configFile,
+	)
+	if err != nil {
+		return
+	}
+
+	renderConfigTemplate(
+		config,
+		makeVariables(config, *simulateVariableList),
+	)
+
+	var timeRegexp *regexp.Regexp
+	if timePattern != "" {
+		timeRegexp, err = regexp.Compile(timePattern)
+		if err != nil {
+			return
+		}
+	}
+
+	var reader io.Reader = os.Stdin
+	if logFile != "-" {
+		var file *os.File
+		file, err = os.Open(logFile)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	lines, err := readTimedLines(
+		reader,
+		timeRegexp,
+		timeLayout,
+		time.Duration(float64(time.Millisecond)*linePace),
+	)
+	if err != nil {
+		return
+	}
+
+	var start time.Time
+	end := start
+	if len(lines) > 0 {
+		start = lines[0].Time
+		end = lines[len(lines)-1].Time
+	}
+	end = end.Add(time.Duration(float64(time.Millisecond) * runAfter))
+
+	stateChanges := runner.Simulate(
+		config.Script,
+		start,
+		lines,
+		end,
+	)
+
+	fmt.Printf("%10s  %s\n", "+0s", config.Script.LeafState(config.Script.InitialState))
+	for _, stateChange := range stateChanges {
+		printStateChange(stateChange, start)
+	}
+
+	return
+}
+
+/*
+readTimedLines reads lines and gives every line a time, either from the
+timestamp in the line or by adding pace to the time of the previous line.
+The time never goes back. Lines before the first timestamp, like a banner,
+get the time of the first timestamp.
+*/
+func readTimedLines(
+	reader io.Reader,
+	timeRegexp *regexp.Regexp,
+	timeLayout string,
+	pace time.Duration,
+) (
+	lines []runner.TimedLine,
+	err error,
+) {
+	var now time.Time
+	timed := false
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(utils.StripSpecial(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		lineTime, ok := parseLineTime(line, timeRegexp, timeLayout)
+		switch {
+		case !ok:
+			if len(lines) > 0 {
+				now = now.Add(pace)
+			}
+
+		case !timed:
+			for index := range lines {
+				lines[index].Time = lineTime
+			}
+			now = lineTime
+			timed = true
+
+		case lineTime.After(now):
+			now = lineTime
+		}
+
+		lines = append(lines, runner.TimedLine{
+			Line: line,
+			Time: now,
+		})
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// parseLineTime finds and parses the timestamp in a line
+func parseLineTime(
+	line string,
+	timeRegexp *regexp.Regexp,
+	timeLayout string,
+) (
+	lineTime time.Time,
+	ok bool,
+) {
+	if timeRegexp == nil {
+		return
+	}
+
+	match := timeRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+
+	value := match[0]
+	if len(match) > 1 {
+		value = match[1]
+	}
+
+	lineTime, err := time.Parse(timeLayout, value)
+	if err != nil {
+		return
+	}
+
+	ok = true
+	return
+}
+
+// printStateChange prints a state change and its actions
+func printStateChange(
+	stateChange runner.StateChange,
+	start time.Time,
+) {
+	offset := "+" + stateChange.Time.Sub(start).String()
+
+	// the initial state is printed already, only its actions are new
+	if stateChange.PrevState != "" {
+		fmt.Printf(
+			"%10s  %s -> %s (%s)",
+			offset,
+			stateChange.PrevState,
+			stateChange.NextState,
+			stateChange.Event,
+		)
+		if stateChange.Line != "" {
+			fmt.Printf(" %q", stateChange.Line)
+		}
+		fmt.Println()
+	}
+
+	for _, actionUnknown := range stateChange.Actions {
+		switch action := actionUnknown.(type) {
+		case runner.CommandAction:
+			for _, command := range strings.Split(strings.TrimSpace(action.Command), "\n") {
+				fmt.Printf("%10s  > %s\n", "", command)
+			}
+
+		case runner.SignalAction:
+			fmt.Printf("%10s  signal %v\n", "", action.Signal)
+
+		case runner.KillAction:
+			fmt.Printf("%10s  kill\n", "")
+
+		case runner.WaitAction:
+			fmt.Printf("%10s  wait %v\n", "", action.Interval)
+
+		case runner.ShutdownAction:
+			fmt.Printf("%10s  shutdown", "")
+			if action.Command != "" {
+				fmt.Printf(" command %q", action.Command)
+			}
+			if action.Signal != nil {
+				fmt.Printf(" signal %v", action.Signal)
+			}
+			fmt.Printf(" timeout %v\n", action.Timeout)
+
+		case runner.HTTPAction:
+			fmt.Printf("%10s  http %s %s\n", "", action.URL, action.Body)
+
+		case runner.ExecAction:
+			fmt.Printf("%10s  exec %s\n", "", strings.Join(append([]string{action.Path}, action.Args...), " "))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTimedLines(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	lines, err := readTimedLines(
+		strings.NewReader(`Counter-Strike: Global Offensive
+Network: IP 0.0.0.0
+L 04/07/2020 - 12:00:00: Loading map "de_dust2"
+L 04/07/2020 - 12:00:05: Match is LIVE
+no timestamp
+L 04/07/2020 - 12:00:01: out of order
+`),
+		regexp.MustCompile(`^L (\S+ - \S+):`),
+		"01/02/2006 - 15:04:05",
+		time.Second,
+	)
+	if err != nil {
+		return
+	}
+
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+	assert.Equal(test, []runner.TimedLine{
+		// the banner happens when the log starts
+		{Line: "Counter-Strike: Global Offensive", Time: start},
+		{Line: "Network: IP 0.0.0.0", Time: start},
+		{Line: `L 04/07/2020 - 12:00:00: Loading map "de_dust2"`, Time: start},
+		{Line: "L 04/07/2020 - 12:00:05: Match is LIVE", Time: start.Add(time.Second * 5)},
+		{Line: "no timestamp", Time: start.Add(time.Second * 6)},
+		// the time never goes back
+		{Line: "L 04/07/2020 - 12:00:01: out of order", Time: start.Add(time.Second * 6)},
+	}, lines)
+}
@@ -1,6 +1,10 @@
 package command
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/Gameye/igniter-shell-go/shell"
 	"github.com/spf13/cobra"
 )
 
@@ -9,6 +13,8 @@ var VerifyCommand = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify a config-file",
 	RunE:  runVerifyCommand,
+	// problems are reported already, usage would only hide them
+	SilenceUsage: true,
 }
 
 func init() {
@@ -31,13 +37,22 @@ func runVerifyCommand(
 ) (
 	err error,
 ) {
-	_, err = loadConfig(
+	config, err := loadConfig(
 		configFile,
 	)
 	if err != nil {
 		return
 	}
 
+	errs := shell.Validate(config)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		err = fmt.Errorf("found %d problem(s) in %s", len(errs), configFile)
+		return
+	}
+
 	println("seems to be ok :-)")
 
 	return
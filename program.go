@@ -1,9 +1,14 @@
 package main
 
 import (
+	"os"
+
 	"github.com/Gameye/igniter-shell-go/command"
 )
 
 func main() {
-	_ = command.RootCommand.Execute()
+	err := command.RootCommand.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,467 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Condition is a guard over runner variables, like `players >= 2` or
+`mode == "competitive" && map != "de_dust2"`. Values are compared as numbers
+if both sides are numbers and as strings otherwise. A variable that is not
+set is empty, a value is true if it is not empty, "0" or "false".
+*/
+type Condition struct {
+	source string
+	root   conditionNode
+	// err is why the source could not be parsed
+	err error
+}
+
+/*
+ParseCondition parses a condition
+*/
+func ParseCondition(
+	source string,
+) (
+	condition *Condition,
+	err error,
+) {
+	tokens, err := tokenizeCondition(source)
+	if err != nil {
+		err = fmt.Errorf("invalid condition %q: %v", source, err)
+		return
+	}
+
+	parser := conditionParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && parser.position < len(parser.tokens) {
+		err = fmt.Errorf("unexpected %q", parser.tokens[parser.position].text)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid condition %q: %v", source, err)
+		return
+	}
+
+	condition = &Condition{
+		source: source,
+		root:   root,
+	}
+	return
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling. A condition that can not be
+parsed is kept with its error, so Validate can report it with the other
+problems of the config.
+*/
+func (condition *Condition) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source string
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	parsed, parseErr := ParseCondition(source)
+	if parseErr != nil {
+		*condition = Condition{
+			source: source,
+			err:    parseErr,
+		}
+		return
+	}
+	*condition = *parsed
+
+	return
+}
+
+/*
+Err returns why the condition could not be parsed, or nil
+*/
+func (condition *Condition) Err() error {
+	return condition.err
+}
+
+/*
+String returns the condition as it was configured
+*/
+func (condition *Condition) String() string {
+	return condition.source
+}
+
+/*
+Holds evaluates the condition with variables, a nil condition always holds
+and a condition that could not be parsed never does
+*/
+func (condition *Condition) Holds(
+	variables map[string]string,
+) bool {
+	if condition == nil {
+		return true
+	}
+	if condition.err != nil {
+		return false
+	}
+	return conditionTruth(condition.root.evaluate(variables))
+}
+
+type conditionNode interface {
+	evaluate(variables map[string]string) string
+}
+
+type conditionLiteral struct {
+	value string
+}
+
+func (node conditionLiteral) evaluate(
+	variables map[string]string,
+) string {
+	return node.value
+}
+
+type conditionVariable struct {
+	name string
+}
+
+func (node conditionVariable) evaluate(
+	variables map[string]string,
+) string {
+	return variables[node.name]
+}
+
+type conditionNot struct {
+	operand conditionNode
+}
+
+func (node conditionNot) evaluate(
+	variables map[string]string,
+) string {
+	return strconv.FormatBool(!conditionTruth(node.operand.evaluate(variables)))
+}
+
+type conditionBinary struct {
+	operator string
+	left     conditionNode
+	right    conditionNode
+}
+
+func (node conditionBinary) evaluate(
+	variables map[string]string,
+) string {
+	left := node.left.evaluate(variables)
+
+	// the right side is only evaluated when needed
+	switch node.operator {
+	case "&&":
+		return strconv.FormatBool(
+			conditionTruth(left) && conditionTruth(node.right.evaluate(variables)),
+		)
+	case "||":
+		return strconv.FormatBool(
+			conditionTruth(left) || conditionTruth(node.right.evaluate(variables)),
+		)
+	}
+
+	comparison := compareConditionValues(left, node.right.evaluate(variables))
+	switch node.operator {
+	case "==":
+		return strconv.FormatBool(comparison == 0)
+	case "!=":
+		return strconv.FormatBool(comparison != 0)
+	case "<":
+		return strconv.FormatBool(comparison < 0)
+	case "<=":
+		return strconv.FormatBool(comparison <= 0)
+	case ">":
+		return strconv.FormatBool(comparison > 0)
+	case ">=":
+		return strconv.FormatBool(comparison >= 0)
+	}
+
+	return ""
+}
+
+// compareConditionValues compares as numbers if possible
+func compareConditionValues(
+	left string,
+	right string,
+) int {
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr != nil || rightErr != nil {
+		return strings.Compare(left, right)
+	}
+
+	switch {
+	case leftNumber < rightNumber:
+		return -1
+	case leftNumber > rightNumber:
+		return 1
+	}
+	return 0
+}
+
+func conditionTruth(
+	value string,
+) bool {
+	return value != "" && value != "0" && value != "false"
+}
+
+type conditionTokenKind int
+
+const (
+	conditionOperator conditionTokenKind = iota
+	conditionIdentifier
+	conditionString
+	conditionNumber
+)
+
+type conditionToken struct {
+	kind conditionTokenKind
+	text string
+}
+
+// conditionOperators are ordered so longer operators are matched first
+var conditionOperators = []string{
+	"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")",
+}
+
+func tokenizeCondition(
+	source string,
+) (
+	tokens []conditionToken,
+	err error,
+) {
+	runes := []rune(source)
+	for position := 0; position < len(runes); {
+		r := runes[position]
+
+		switch {
+		case unicode.IsSpace(r):
+			position++
+
+		case r == '"' || r == '\'':
+			var value strings.Builder
+			end := position + 1
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				value.WriteRune(runes[end])
+			}
+			if end >= len(runes) {
+				err = fmt.Errorf("unterminated string")
+				return
+			}
+			tokens = append(tokens, conditionToken{conditionString, value.String()})
+			position = end + 1
+
+		case unicode.IsDigit(r) ||
+			(r == '-' && position+1 < len(runes) && unicode.IsDigit(runes[position+1])):
+			end := position + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, conditionToken{conditionNumber, string(runes[position:end])})
+			position = end
+
+		case unicode.IsLetter(r) || r == '_':
+			end := position + 1
+			for end < len(runes) &&
+				(unicode.IsLetter(runes[end]) ||
+					unicode.IsDigit(runes[end]) ||
+					runes[end] == '_' ||
+					runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, conditionToken{conditionIdentifier, string(runes[position:end])})
+			position = end
+
+		default:
+			operator := ""
+			for _, candidate := range conditionOperators {
+				if strings.HasPrefix(string(runes[position:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				err = fmt.Errorf("unexpected %q", string(r))
+				return
+			}
+			tokens = append(tokens, conditionToken{conditionOperator, operator})
+			position += len([]rune(operator))
+		}
+	}
+
+	return
+}
+
+// conditionParser is a recursive descent parser for conditions
+type conditionParser struct {
+	tokens   []conditionToken
+	position int
+}
+
+func (parser *conditionParser) peekOperator(
+	operators ...string,
+) (
+	operator string,
+	ok bool,
+) {
+	if parser.position >= len(parser.tokens) {
+		return
+	}
+	token := parser.tokens[parser.position]
+	if token.kind != conditionOperator {
+		return
+	}
+	for _, candidate := range operators {
+		if token.text == candidate {
+			operator = candidate
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+func (parser *conditionParser) parseOr() (
+	node conditionNode,
+	err error,
+) {
+	node, err = parser.parseAnd()
+	if err != nil {
+		return
+	}
+	for {
+		operator, ok := parser.peekOperator("||")
+		if !ok {
+			return
+		}
+		parser.position++
+
+		var right conditionNode
+		right, err = parser.parseAnd()
+		if err != nil {
+			return
+		}
+		node = conditionBinary{operator, node, right}
+	}
+}
+
+func (parser *conditionParser) parseAnd() (
+	node conditionNode,
+	err error,
+) {
+	node, err = parser.parseNot()
+	if err != nil {
+		return
+	}
+	for {
+		operator, ok := parser.peekOperator("&&")
+		if !ok {
+			return
+		}
+		parser.position++
+
+		var right conditionNode
+		right, err = parser.parseNot()
+		if err != nil {
+			return
+		}
+		node = conditionBinary{operator, node, right}
+	}
+}
+
+func (parser *conditionParser) parseNot() (
+	node conditionNode,
+	err error,
+) {
+	if _, ok := parser.peekOperator("!"); ok {
+		parser.position++
+
+		var operand conditionNode
+		operand, err = parser.parseNot()
+		if err != nil {
+			return
+		}
+		node = conditionNot{operand}
+		return
+	}
+
+	return parser.parseComparison()
+}
+
+func (parser *conditionParser) parseComparison() (
+	node conditionNode,
+	err error,
+) {
+	node, err = parser.parseOperand()
+	if err != nil {
+		return
+	}
+
+	operator, ok := parser.peekOperator("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return
+	}
+	parser.position++
+
+	right, err := parser.parseOperand()
+	if err != nil {
+		return
+	}
+	node = conditionBinary{operator, node, right}
+
+	return
+}
+
+func (parser *conditionParser) parseOperand() (
+	node conditionNode,
+	err error,
+) {
+	if parser.position >= len(parser.tokens) {
+		err = fmt.Errorf("unexpected end")
+		return
+	}
+	token := parser.tokens[parser.position]
+	parser.position++
+
+	switch token.kind {
+	case conditionString, conditionNumber:
+		node = conditionLiteral{token.text}
+
+	case conditionIdentifier:
+		switch token.text {
+		case "true", "false":
+			node = conditionLiteral{token.text}
+		default:
+			node = conditionVariable{token.text}
+		}
+
+	case conditionOperator:
+		if token.text != "(" {
+			err = fmt.Errorf("unexpected %q", token.text)
+			return
+		}
+		node, err = parser.parseOr()
+		if err != nil {
+			return
+		}
+		if _, ok := parser.peekOperator(")"); !ok {
+			err = fmt.Errorf("missing \")\"")
+			return
+		}
+		parser.position++
+	}
+
+	return
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition(test *testing.T) {
+	variables := map[string]string{
+		"players":  "10",
+		"mode":     "competitive",
+		"map":      "de_dust2",
+		"arg.mode": "competitive",
+		"bots":     "0",
+	}
+
+	for source, expected := range map[string]bool{
+		`players >= 2`:                          true,
+		`players < 9`:                           false,
+		`players == 10.0`:                       true,
+		`mode == "competitive"`:                 true,
+		`mode == 'casual'`:                      false,
+		`mode != "casual" && map == "de_dust2"`: true,
+		`mode == "casual" || map == "de_dust2"`: true,
+		`mode == arg.mode`:                      true,
+		`!(players > 5)`:                        false,
+		`bots`:                                  false,
+		`!bots && players`:                      true,
+		`unknown == ""`:                         true,
+		`unknown`:                               false,
+		`true && (false || players > -1)`:       true,
+		`mode == "comp\"etitive"`:               false,
+		`mode == "casual" || mode == "wingman" || map`: true,
+	} {
+		condition, err := ParseCondition(source)
+		if !assert.NoError(test, err, source) {
+			continue
+		}
+		assert.Equal(test, expected, condition.Holds(variables), source)
+		assert.Equal(test, source, condition.String())
+	}
+
+	var condition *Condition
+	assert.True(test, condition.Holds(variables))
+}
+
+func TestInvalidCondition(test *testing.T) {
+	for source, message := range map[string]string{
+		`players >=`:       `invalid condition "players >=": unexpected end`,
+		`mode == "casual`:  `invalid condition "mode == \"casual": unterminated string`,
+		`(players > 2`:     `invalid condition "(players > 2": missing ")"`,
+		`players > 2 mode`: `invalid condition "players > 2 mode": unexpected "mode"`,
+		`players = 2`:      `invalid condition "players = 2": unexpected "="`,
+		`== 2`:             `invalid condition "== 2": unexpected "=="`,
+	} {
+		_, err := ParseCondition(source)
+		if assert.Error(test, err, source) {
+			assert.Equal(test, message, err.Error())
+		}
+
+		// decoding keeps the error for validation
+		var condition Condition
+		data, _ := json.Marshal(source)
+		if assert.NoError(test, json.Unmarshal(data, &condition), source) {
+			assert.EqualError(test, condition.Err(), message)
+			assert.False(test, condition.Holds(nil))
+		}
+	}
+}
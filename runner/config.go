@@ -15,6 +15,36 @@ type Config struct {
 	InitialState string               `json:"initialState"`
 	States       StateConfigMap       `json:"states"`
 	Transitions  TransitionConfigList `json:"transitions"`
+	// Variables are set before the runner starts, like launch variables
+	Variables map[string]string `json:"-"`
+}
+
+/*
+ExitCode returns the exit code the shell should exit with when the runner
+ends in state, ok is false if the exit code of the process should be used
+because state is not a configured final state
+*/
+func (config *Config) ExitCode(
+	state string,
+) (
+	code int,
+	ok bool,
+) {
+	if stateConfig, configured := config.States[state]; configured && stateConfig.Final {
+		code, ok = stateConfig.ExitCode, true
+	}
+	return
+}
+
+/*
+IsFinal tells if the runner stops in state, this is a state that is marked
+final or a state that is not configured
+*/
+func (config *Config) IsFinal(
+	state string,
+) bool {
+	stateConfig, ok := config.States[state]
+	return !ok || stateConfig.Final
 }
 
 /*
@@ -65,6 +95,101 @@ type KillTransitionConfig struct {
 	To   string `json:"to"`
 }
 
+/*
+WaitTransitionConfig delays the actions that follow it
+*/
+type WaitTransitionConfig struct {
+	From     string
+	To       string
+	Interval time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *WaitTransitionConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		From     string  `json:"from"`
+		To       string  `json:"to"`
+		Interval float64 `json:"interval"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = WaitTransitionConfig{
+		From:     source.From,
+		To:       source.To,
+		Interval: time.Duration(float64(time.Millisecond) * source.Interval),
+	}
+
+	return
+}
+
+/*
+ShutdownTransitionConfig stops the process by sending a command or a signal,
+if the process does not exit within the timeout it is terminated and finally
+killed
+*/
+type ShutdownTransitionConfig struct {
+	From    string
+	To      string
+	Command string
+	Signal  os.Signal
+	Timeout time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *ShutdownTransitionConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		From    string  `json:"from"`
+		To      string  `json:"to"`
+		Command string  `json:"command"`
+		Signal  string  `json:"signal"`
+		Timeout float64 `json:"timeout"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = ShutdownTransitionConfig{
+		From:    source.From,
+		To:      source.To,
+		Command: source.Command,
+		Timeout: time.Duration(float64(time.Millisecond) * source.Timeout),
+	}
+	if source.Signal != "" {
+		target.Signal = parseSignalConfig(source.Signal)
+	}
+
+	return
+}
+
+/*
+SequenceTransitionConfig transitions with a list of actions that are
+performed in order. The actions are configured like transitions, their from
+and to are ignored.
+*/
+type SequenceTransitionConfig struct {
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	Actions TransitionConfigList `json:"actions"`
+}
+
 /*
 SignalTransitionConfig transitions with a signal
 */
@@ -93,8 +218,25 @@ func (target *SignalTransitionConfig) UnmarshalJSON(
 		return
 	}
 
-	var signal os.Signal
-	switch source.Signal {
+	*target = SignalTransitionConfig{
+		From:   source.From,
+		To:     source.To,
+		Signal: parseSignalConfig(source.Signal),
+	}
+
+	return
+}
+
+/*
+ParseSignal returns the signal with the given name, or nil if the name is
+unknown
+*/
+func ParseSignal(
+	name string,
+) (
+	signal os.Signal,
+) {
+	switch name {
 
 	case "SIGABRT":
 		signal = syscall.Signal(0x6)
@@ -168,15 +310,149 @@ func (target *SignalTransitionConfig) UnmarshalJSON(
 		signal = syscall.Signal(0x19)
 	}
 
-	*target = SignalTransitionConfig{
-		From:   source.From,
-		To:     source.To,
-		Signal: signal,
+	return
+}
+
+/*
+UnknownSignal is a signal with a name that is not known, sending it to a
+process always fails
+*/
+type UnknownSignal string
+
+/*
+String returns the name of the signal
+*/
+func (signal UnknownSignal) String() string {
+	return string(signal)
+}
+
+/*
+Signal makes UnknownSignal an os.Signal
+*/
+func (signal UnknownSignal) Signal() {}
+
+// parseSignalConfig parses a signal from config and keeps unknown names
+func parseSignalConfig(
+	name string,
+) (
+	signal os.Signal,
+) {
+	signal = ParseSignal(name)
+	if signal == nil {
+		signal = UnknownSignal(name)
+	}
+	return
+}
+
+/*
+HTTPTransitionConfig posts Body as json to URL. The url and every string in
+the body are rendered with the variables. A request that fails is tried
+again up to Retries times, every attempt may take up to Timeout.
+*/
+type HTTPTransitionConfig struct {
+	From    string
+	To      string
+	URL     string
+	Body    interface{}
+	Retries int
+	Timeout time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *HTTPTransitionConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		From    string      `json:"from"`
+		To      string      `json:"to"`
+		URL     string      `json:"url"`
+		Body    interface{} `json:"body"`
+		Retries int         `json:"retries"`
+		Timeout float64     `json:"timeout"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = HTTPTransitionConfig{
+		From:    source.From,
+		To:      source.To,
+		URL:     source.URL,
+		Body:    source.Body,
+		Retries: source.Retries,
+		Timeout: time.Duration(float64(time.Millisecond) * source.Timeout),
 	}
 
 	return
 }
 
+/*
+ExecTransitionConfig runs the program at Path with Args. The path, the
+arguments and the values of Env are rendered with the variables. Env is
+added to the environment of the shell. If Lines is true the output of the
+program is handled like output of the process. A program that runs longer
+than Timeout is killed, a zero Timeout lets it run until it ends.
+*/
+type ExecTransitionConfig struct {
+	From    string
+	To      string
+	Path    string
+	Args    []string
+	Env     map[string]string
+	Lines   bool
+	Timeout time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *ExecTransitionConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		From    string            `json:"from"`
+		To      string            `json:"to"`
+		Path    string            `json:"path"`
+		Args    []string          `json:"args"`
+		Env     map[string]string `json:"env"`
+		Lines   bool              `json:"lines"`
+		Timeout float64           `json:"timeout"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = ExecTransitionConfig{
+		From:    source.From,
+		To:      source.To,
+		Path:    source.Path,
+		Args:    source.Args,
+		Env:     source.Env,
+		Lines:   source.Lines,
+		Timeout: time.Duration(float64(time.Millisecond) * source.Timeout),
+	}
+
+	return
+}
+
+/*
+UnknownTransitionConfig is a transition with a type that is not known, it
+has no actions
+*/
+type UnknownTransitionConfig struct {
+	Type string
+}
+
 /*
 transitionConfigJSON helper
 */
@@ -227,6 +503,51 @@ func (config *transitionConfigJSON) UnmarshalJSON(
 		}
 		config.Payload = payload
 
+	case "wait":
+		var payload WaitTransitionConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "shutdown":
+		var payload ShutdownTransitionConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "sequence":
+		var payload SequenceTransitionConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "http":
+		var payload HTTPTransitionConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "exec":
+		var payload ExecTransitionConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	default:
+		config.Payload = UnknownTransitionConfig{
+			Type: item.Type,
+		}
+
 	}
 
 	return
@@ -238,10 +559,27 @@ StateConfigMap map of StateConfig
 type StateConfigMap map[string]StateConfig
 
 /*
-StateConfig is a transition from one state to another.
+StateConfig is a transition from one state to another. The OnExit actions
+are performed when the runner leaves the state, before the actions of the
+transitions, the OnEnter actions when it enters the state, after them. The
+actions are configured like transitions, their from and to are ignored.
+
+A state with a Parent is a child of that state, the events of the parent
+apply to the child after its own events. A state that has children is a
+composite state, the runner enters it through its InitialState.
+
+The runner stops in a Final state, the shell then exits with ExitCode. The
+values of Result are rendered with the variables when the state is entered.
 */
 type StateConfig struct {
-	Events EventConfigList `json:"events"`
+	Events       EventConfigList      `json:"events"`
+	OnEnter      TransitionConfigList `json:"onEnter"`
+	OnExit       TransitionConfigList `json:"onExit"`
+	Parent       string               `json:"parent"`
+	InitialState string               `json:"initialState"`
+	Final        bool                 `json:"final"`
+	ExitCode     int                  `json:"exitCode"`
+	Result       map[string]string    `json:"result"`
 }
 
 /*
@@ -279,17 +617,31 @@ type EventConfig interface{}
 LiteralEventConfig configures literal events
 */
 type LiteralEventConfig struct {
-	NextState  string `json:"nextState"`
-	Value      string `json:"value"`
-	IgnoreCase bool   `json:"ignoreCase"`
+	CounterConfig
+	NextState  string     `json:"nextState"`
+	Value      string     `json:"value"`
+	IgnoreCase bool       `json:"ignoreCase"`
+	When       *Condition `json:"when"`
+}
+
+/*
+CounterConfig names the counters that an event changes when it matches a
+line, counters are runner variables
+*/
+type CounterConfig struct {
+	Increment string `json:"increment"`
+	Decrement string `json:"decrement"`
+	Reset     string `json:"reset"`
 }
 
 /*
 RegexEventConfig configures regex events
 */
 type RegexEventConfig struct {
+	CounterConfig
 	NextState string
 	Regexp    *regexp.Regexp
+	When      *Condition
 }
 
 /*
@@ -301,9 +653,11 @@ func (target *RegexEventConfig) UnmarshalJSON(
 	err error,
 ) {
 	var source struct {
-		NextState  string `json:"nextState"`
-		Pattern    string `json:"pattern"`
-		IgnoreCase bool   `json:"ignoreCase"`
+		CounterConfig
+		NextState  string     `json:"nextState"`
+		Pattern    string     `json:"pattern"`
+		IgnoreCase bool       `json:"ignoreCase"`
+		When       *Condition `json:"when"`
 	}
 
 	err = json.Unmarshal(data, &source)
@@ -324,8 +678,10 @@ func (target *RegexEventConfig) UnmarshalJSON(
 		}
 	}
 	*target = RegexEventConfig{
-		NextState: source.NextState,
-		Regexp:    re,
+		CounterConfig: source.CounterConfig,
+		NextState:     source.NextState,
+		Regexp:        re,
+		When:          source.When,
 	}
 
 	return
@@ -337,6 +693,7 @@ TimerEventConfig configures timer events
 type TimerEventConfig struct {
 	NextState string
 	Interval  time.Duration
+	When      *Condition
 }
 
 /*
@@ -348,8 +705,9 @@ func (target *TimerEventConfig) UnmarshalJSON(
 	err error,
 ) {
 	var source struct {
-		NextState string  `json:"nextState"`
-		Interval  float64 `json:"interval"`
+		NextState string     `json:"nextState"`
+		Interval  float64    `json:"interval"`
+		When      *Condition `json:"when"`
 	}
 
 	err = json.Unmarshal(data, &source)
@@ -360,11 +718,155 @@ func (target *TimerEventConfig) UnmarshalJSON(
 	*target = TimerEventConfig{
 		NextState: source.NextState,
 		Interval:  time.Duration(float64(time.Millisecond) * source.Interval),
+		When:      source.When,
 	}
 
 	return
 }
 
+/*
+IdleEventConfig configures idle events, they happen when no line was seen for
+Interval since the last line or since the state was entered. If Regexp is
+set, only lines that match it count.
+*/
+type IdleEventConfig struct {
+	NextState string
+	Interval  time.Duration
+	Regexp    *regexp.Regexp
+	When      *Condition
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *IdleEventConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		NextState  string     `json:"nextState"`
+		Interval   float64    `json:"interval"`
+		Pattern    string     `json:"pattern"`
+		IgnoreCase bool       `json:"ignoreCase"`
+		When       *Condition `json:"when"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = IdleEventConfig{
+		NextState: source.NextState,
+		Interval:  time.Duration(float64(time.Millisecond) * source.Interval),
+		When:      source.When,
+	}
+	if source.Pattern != "" {
+		pattern := source.Pattern
+		if source.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		target.Regexp, err = regexp.Compile(pattern)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+ThresholdEventConfig configures threshold events, they happen when a line is
+handled and Counter is at least Value. If Window is set, only the changes to
+the counter within the window count.
+*/
+type ThresholdEventConfig struct {
+	NextState string
+	Counter   string
+	Value     int
+	Window    time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *ThresholdEventConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		NextState string  `json:"nextState"`
+		Counter   string  `json:"counter"`
+		Value     int     `json:"value"`
+		Window    float64 `json:"window"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = ThresholdEventConfig{
+		NextState: source.NextState,
+		Counter:   source.Counter,
+		Value:     source.Value,
+		Window:    time.Duration(float64(time.Millisecond) * source.Window),
+	}
+
+	return
+}
+
+/*
+ExitEventConfig configures exit events, they happen when the process exits.
+If Code is set, only that exit code matches, if Signal is set, only an exit
+caused by that signal matches.
+*/
+type ExitEventConfig struct {
+	NextState string
+	Code      *int
+	Signal    os.Signal
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *ExitEventConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		NextState string `json:"nextState"`
+		Code      *int   `json:"code"`
+		Signal    string `json:"signal"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = ExitEventConfig{
+		NextState: source.NextState,
+		Code:      source.Code,
+	}
+	if source.Signal != "" {
+		target.Signal = parseSignalConfig(source.Signal)
+	}
+
+	return
+}
+
+/*
+UnknownEventConfig is an event with a type that is not known, it never
+happens
+*/
+type UnknownEventConfig struct {
+	Type string
+}
+
 /*
 eventConfigJSON helper
 */
@@ -415,6 +917,35 @@ func (config *eventConfigJSON) UnmarshalJSON(
 		}
 		config.Payload = payload
 
+	case "idle":
+		var payload IdleEventConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "threshold":
+		var payload ThresholdEventConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "exit":
+		var payload ExitEventConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	default:
+		config.Payload = UnknownEventConfig{
+			Type: item.Type,
+		}
+
 	}
 
 	return
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"regexp"
+	"syscall"
 	"testing"
 	"time"
 
@@ -33,6 +34,420 @@ func TestDecodeRunnerConfig(test *testing.T) {
 	assert.Equal(test, *makeLightTestConfig(), config)
 }
 
+func TestDecodeSequenceConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "playing",
+		"states": {
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "quit", "nextState": "quit" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "to": "quit", "command": "say bye" },
+			{
+				"type": "sequence",
+				"from": "playing",
+				"to": "quit",
+				"actions": [
+					{ "type": "command", "command": "quit" },
+					{ "type": "wait", "interval": 5000 },
+					{ "type": "signal", "signal": "SIGTERM" },
+					{ "type": "kill" }
+				]
+			}
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeSequenceTestConfig(), config)
+}
+
+func TestDecodeEnterExitConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "lobby",
+		"states": {
+			"lobby": {
+				"events": [
+					{ "type": "literal", "value": "Match is LIVE", "nextState": "playing" }
+				],
+				"onExit": [
+					{ "type": "command", "command": "say good luck" }
+				]
+			},
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "Match ended", "nextState": "quit" }
+				],
+				"onEnter": [
+					{ "type": "command", "command": "echo Playing..." }
+				],
+				"onExit": [
+					{ "type": "command", "command": "tv_stoprecord" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "from": "lobby", "to": "playing", "command": "tv_record match" },
+			{ "type": "command", "to": "quit", "command": "quit" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeEnterExitTestConfig(), config)
+}
+
+func TestDecodeExitConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "playing",
+		"states": {
+			"playing": {
+				"events": [
+					{ "type": "exit", "code": 0, "nextState": "finished" },
+					{ "type": "exit", "signal": "SIGSEGV", "nextState": "crashed" },
+					{ "type": "exit", "nextState": "failed" }
+				]
+			},
+			"finished": { "final": true },
+			"crashed": { "final": true, "exitCode": 2 },
+			"failed": { "final": true, "exitCode": 3 }
+		},
+		"transitions": [
+			{ "type": "kill", "to": "crashed" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeExitTestConfig(), config)
+}
+
+func TestDecodeFinalConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "playing",
+		"states": {
+			"playing": {
+				"events": [
+					{ "type": "regex", "pattern": "^Team (?P<winner>\\w+) won$", "nextState": "completed" },
+					{ "type": "literal", "value": "Invalid token", "nextState": "token_invalid" }
+				]
+			},
+			"completed": {
+				"final": true,
+				"result": { "outcome": "completed", "winner": "${winner}" },
+				"onEnter": [
+					{ "type": "command", "command": "quit" }
+				]
+			},
+			"token_invalid": {
+				"final": true,
+				"exitCode": 3
+			}
+		}
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeFinalTestConfig(), config)
+}
+
+func TestDecodeHTTPConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "idle",
+		"states": {
+			"idle": {
+				"events": [
+					{ "type": "regex", "pattern": "^Loading map (?P<map>\\w+)$", "nextState": "playing" }
+				]
+			},
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "quit", "nextState": "quit" }
+				]
+			}
+		},
+		"transitions": [
+			{
+				"type": "http",
+				"to": "playing",
+				"url": "${api}/matches/${match}",
+				"body": { "state": "playing", "map": "${map}", "rounds": [1, "${map}"] },
+				"retries": 3,
+				"timeout": 2000
+			},
+			{ "type": "kill", "to": "quit" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeHTTPTestConfig(), config)
+}
+
+func TestDecodeExecConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "playing",
+		"states": {
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "Match ended", "nextState": "uploading" }
+				]
+			},
+			"uploading": {
+				"events": [
+					{ "type": "literal", "value": "upload done", "nextState": "quit" },
+					{ "type": "timer", "interval": 60000, "nextState": "quit" }
+				]
+			}
+		},
+		"transitions": [
+			{
+				"type": "exec",
+				"to": "uploading",
+				"path": "/scripts/upload.sh",
+				"args": ["--match", "${match}"],
+				"env": { "DEMO": "${match}.dem" },
+				"lines": true,
+				"timeout": 30000
+			},
+			{ "type": "kill", "to": "quit" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeExecTestConfig(), config)
+}
+
+func TestDecodeIdleConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "playing",
+		"states": {
+			"playing": {
+				"events": [
+					{ "type": "idle", "interval": 60000, "nextState": "hung" },
+					{
+						"type": "idle",
+						"interval": 300000,
+						"pattern": "connected$",
+						"ignoreCase": true,
+						"nextState": "empty"
+					}
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "to": "hung", "command": "status" },
+			{ "type": "kill", "to": "empty" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeIdleTestConfig(), config)
+}
+
+func TestDecodeCounterConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "waiting",
+		"states": {
+			"waiting": {
+				"events": [
+					{ "type": "regex", "pattern": "^\\w+ connected$", "increment": "players" },
+					{ "type": "regex", "pattern": "^\\w+ disconnected$", "decrement": "players" },
+					{ "type": "literal", "value": "Map change failed", "increment": "failures" },
+					{ "type": "threshold", "counter": "players", "value": 3, "nextState": "playing" },
+					{
+						"type": "threshold",
+						"counter": "failures",
+						"value": 5,
+						"window": 60000,
+						"nextState": "broken"
+					}
+				]
+			},
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "Match ended", "reset": "players", "nextState": "waiting" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "to": "playing", "command": "say ${players} players" },
+			{ "type": "kill", "to": "broken" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeCounterTestConfig(), config)
+}
+
+func TestDecodeGuardConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "waiting",
+		"states": {
+			"waiting": {
+				"events": [
+					{ "type": "regex", "pattern": "^(?P<winner>\\w+) won$", "when": "winner == team", "nextState": "celebrate" },
+					{ "type": "regex", "pattern": "^(?P<players>\\d+) players$", "nextState": "waiting" },
+					{ "type": "literal", "value": "start", "when": "players >= 2", "nextState": "playing" },
+					{ "type": "timer", "interval": 600000, "when": "players < 2", "nextState": "quit" }
+				]
+			},
+			"playing": {
+				"events": [
+					{ "type": "literal", "value": "end", "nextState": "quit" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "to": "celebrate", "command": "say ${winner} won" },
+			{ "type": "kill", "to": "quit" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	config.Variables = map[string]string{"team": "red"}
+	assert.Equal(test, *makeGuardTestConfig(), config)
+
+	err = json.Unmarshal([]byte(`{
+		"states": {
+			"waiting": {
+				"events": [
+					{ "type": "literal", "value": "start", "when": "players >=", "nextState": "playing" }
+				]
+			}
+		}
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	// the error is kept for validation
+	when := config.States["waiting"].Events[0].(LiteralEventConfig).When
+	assert.EqualError(test, when.Err(), `invalid condition "players >=": unexpected end`)
+}
+
+func TestDecodeNestedConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "match",
+		"states": {
+			"match": {
+				"initialState": "warmup",
+				"events": [
+					{ "type": "literal", "value": "quit", "nextState": "quit" },
+					{ "type": "timer", "interval": 3600000, "nextState": "quit" }
+				],
+				"onExit": [
+					{ "type": "command", "command": "tv_stoprecord" }
+				]
+			},
+			"warmup": {
+				"parent": "match",
+				"events": [
+					{ "type": "literal", "value": "Match is LIVE", "nextState": "live" }
+				]
+			},
+			"live": {
+				"parent": "match",
+				"events": [
+					{ "type": "literal", "value": "quit", "nextState": "end" },
+					{ "type": "literal", "value": "restart", "nextState": "match" }
+				]
+			},
+			"end": {
+				"events": [
+					{ "type": "timer", "interval": 10000, "nextState": "quit" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "command", "to": "match", "command": "echo Match..." },
+			{ "type": "command", "from": "warmup", "to": "live", "command": "say live" },
+			{ "type": "kill", "to": "quit" }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, *makeNestedTestConfig(), config)
+}
+
 func makeLightTestConfig() (
 	config *Config,
 ) {
@@ -114,3 +529,585 @@ func makeAutoTestConfig() (
 
 	return
 }
+
+func makeCaptureTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "idle",
+		States: map[string]StateConfig{
+			"idle": StateConfig{
+				Events: []EventConfig{
+					RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^(?P<player>\w+) connected$`),
+						NextState: "greet",
+					},
+				},
+			},
+			"greet": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "greeted",
+						NextState: "idle",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "greet",
+				Command: "say hello ${player}",
+			},
+		},
+	}
+
+	return
+}
+
+func makeSequenceTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "playing",
+		States: map[string]StateConfig{
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "quit",
+						NextState: "quit",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "quit",
+				Command: "say bye",
+			},
+			SequenceTransitionConfig{
+				From: "playing",
+				To:   "quit",
+				Actions: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "quit",
+					},
+					WaitTransitionConfig{
+						Interval: time.Second * 5,
+					},
+					SignalTransitionConfig{
+						Signal: syscall.SIGTERM,
+					},
+					KillTransitionConfig{},
+				},
+			},
+		},
+	}
+
+	return
+}
+
+func makeMatchTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "idle",
+		States: map[string]StateConfig{
+			"idle": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match is LIVE",
+						NextState: "playing",
+					},
+					TimerEventConfig{
+						NextState: "quit",
+						Interval:  time.Minute * 15,
+					},
+				},
+			},
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match ended",
+						NextState: "end",
+					},
+				},
+			},
+			"end": StateConfig{
+				Events: []EventConfig{
+					TimerEventConfig{
+						NextState: "quit",
+						Interval:  time.Second * 10,
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "end",
+				Command: "say bye",
+			},
+			KillTransitionConfig{
+				To: "quit",
+			},
+		},
+	}
+
+	return
+}
+
+func makeEnterExitTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "lobby",
+		States: map[string]StateConfig{
+			"lobby": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match is LIVE",
+						NextState: "playing",
+					},
+				},
+				OnExit: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "say good luck",
+					},
+				},
+			},
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match ended",
+						NextState: "quit",
+					},
+				},
+				OnEnter: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "echo Playing...",
+					},
+				},
+				OnExit: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "tv_stoprecord",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				From:    "lobby",
+				To:      "playing",
+				Command: "tv_record match",
+			},
+			CommandTransitionConfig{
+				To:      "quit",
+				Command: "quit",
+			},
+		},
+	}
+
+	return
+}
+
+func makeNestedTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "match",
+		States: map[string]StateConfig{
+			"match": StateConfig{
+				InitialState: "warmup",
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "quit",
+						NextState: "quit",
+					},
+					TimerEventConfig{
+						Interval:  time.Hour,
+						NextState: "quit",
+					},
+				},
+				OnExit: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "tv_stoprecord",
+					},
+				},
+			},
+			"warmup": StateConfig{
+				Parent: "match",
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match is LIVE",
+						NextState: "live",
+					},
+				},
+			},
+			"live": StateConfig{
+				Parent: "match",
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "quit",
+						NextState: "end",
+					},
+					LiteralEventConfig{
+						Value:     "restart",
+						NextState: "match",
+					},
+				},
+			},
+			"end": StateConfig{
+				Events: []EventConfig{
+					TimerEventConfig{
+						Interval:  time.Second * 10,
+						NextState: "quit",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "match",
+				Command: "echo Match...",
+			},
+			CommandTransitionConfig{
+				From:    "warmup",
+				To:      "live",
+				Command: "say live",
+			},
+			KillTransitionConfig{
+				To: "quit",
+			},
+		},
+	}
+
+	return
+}
+
+func makeExitTestConfig() (
+	config *Config,
+) {
+	zero := 0
+	config = &Config{
+		InitialState: "playing",
+		States: map[string]StateConfig{
+			"playing": StateConfig{
+				Events: []EventConfig{
+					ExitEventConfig{
+						Code:      &zero,
+						NextState: "finished",
+					},
+					ExitEventConfig{
+						Signal:    syscall.SIGSEGV,
+						NextState: "crashed",
+					},
+					ExitEventConfig{
+						NextState: "failed",
+					},
+				},
+			},
+			"finished": StateConfig{
+				Final: true,
+			},
+			"crashed": StateConfig{
+				Final:    true,
+				ExitCode: 2,
+			},
+			"failed": StateConfig{
+				Final:    true,
+				ExitCode: 3,
+			},
+		},
+		Transitions: []TransitionConfig{
+			KillTransitionConfig{
+				To: "crashed",
+			},
+		},
+	}
+
+	return
+}
+
+func makeFinalTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "playing",
+		States: map[string]StateConfig{
+			"playing": StateConfig{
+				Events: []EventConfig{
+					RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^Team (?P<winner>\w+) won$`),
+						NextState: "completed",
+					},
+					LiteralEventConfig{
+						Value:     "Invalid token",
+						NextState: "token_invalid",
+					},
+				},
+			},
+			"completed": StateConfig{
+				Final: true,
+				Result: map[string]string{
+					"outcome": "completed",
+					"winner":  "${winner}",
+				},
+				OnEnter: []TransitionConfig{
+					CommandTransitionConfig{
+						Command: "quit",
+					},
+				},
+			},
+			"token_invalid": StateConfig{
+				Final:    true,
+				ExitCode: 3,
+			},
+		},
+	}
+
+	return
+}
+
+func makeHTTPTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "idle",
+		States: map[string]StateConfig{
+			"idle": StateConfig{
+				Events: []EventConfig{
+					RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^Loading map (?P<map>\w+)$`),
+						NextState: "playing",
+					},
+				},
+			},
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "quit",
+						NextState: "quit",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			HTTPTransitionConfig{
+				To:  "playing",
+				URL: "${api}/matches/${match}",
+				Body: map[string]interface{}{
+					"state":  "playing",
+					"map":    "${map}",
+					"rounds": []interface{}{1.0, "${map}"},
+				},
+				Retries: 3,
+				Timeout: time.Second * 2,
+			},
+			KillTransitionConfig{
+				To: "quit",
+			},
+		},
+	}
+
+	return
+}
+
+func makeExecTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "playing",
+		States: map[string]StateConfig{
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "Match ended",
+						NextState: "uploading",
+					},
+				},
+			},
+			"uploading": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "upload done",
+						NextState: "quit",
+					},
+					TimerEventConfig{
+						Interval:  time.Minute,
+						NextState: "quit",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			ExecTransitionConfig{
+				To:      "uploading",
+				Path:    "/scripts/upload.sh",
+				Args:    []string{"--match", "${match}"},
+				Env:     map[string]string{"DEMO": "${match}.dem"},
+				Lines:   true,
+				Timeout: time.Second * 30,
+			},
+			KillTransitionConfig{
+				To: "quit",
+			},
+		},
+	}
+
+	return
+}
+
+func makeIdleTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "playing",
+		States: map[string]StateConfig{
+			"playing": StateConfig{
+				Events: []EventConfig{
+					IdleEventConfig{
+						Interval:  time.Minute,
+						NextState: "hung",
+					},
+					IdleEventConfig{
+						Interval:  time.Minute * 5,
+						Regexp:    regexp.MustCompile("(?i)connected$"),
+						NextState: "empty",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "hung",
+				Command: "status",
+			},
+			KillTransitionConfig{
+				To: "empty",
+			},
+		},
+	}
+
+	return
+}
+
+func makeCounterTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "waiting",
+		States: map[string]StateConfig{
+			"waiting": StateConfig{
+				Events: []EventConfig{
+					RegexEventConfig{
+						CounterConfig: CounterConfig{Increment: "players"},
+						Regexp:        regexp.MustCompile(`^\w+ connected$`),
+					},
+					RegexEventConfig{
+						CounterConfig: CounterConfig{Decrement: "players"},
+						Regexp:        regexp.MustCompile(`^\w+ disconnected$`),
+					},
+					LiteralEventConfig{
+						CounterConfig: CounterConfig{Increment: "failures"},
+						Value:         "Map change failed",
+					},
+					ThresholdEventConfig{
+						Counter:   "players",
+						Value:     3,
+						NextState: "playing",
+					},
+					ThresholdEventConfig{
+						Counter:   "failures",
+						Value:     5,
+						Window:    time.Minute,
+						NextState: "broken",
+					},
+				},
+			},
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						CounterConfig: CounterConfig{Reset: "players"},
+						Value:         "Match ended",
+						NextState:     "waiting",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "playing",
+				Command: "say ${players} players",
+			},
+			KillTransitionConfig{
+				To: "broken",
+			},
+		},
+	}
+
+	return
+}
+
+func makeGuardTestConfig() (
+	config *Config,
+) {
+	config = &Config{
+		InitialState: "waiting",
+		States: map[string]StateConfig{
+			"waiting": StateConfig{
+				Events: []EventConfig{
+					RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^(?P<winner>\w+) won$`),
+						When:      mustParseCondition("winner == team"),
+						NextState: "celebrate",
+					},
+					RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^(?P<players>\d+) players$`),
+						NextState: "waiting",
+					},
+					LiteralEventConfig{
+						Value:     "start",
+						When:      mustParseCondition("players >= 2"),
+						NextState: "playing",
+					},
+					TimerEventConfig{
+						Interval:  time.Minute * 10,
+						When:      mustParseCondition("players < 2"),
+						NextState: "quit",
+					},
+				},
+			},
+			"playing": StateConfig{
+				Events: []EventConfig{
+					LiteralEventConfig{
+						Value:     "end",
+						NextState: "quit",
+					},
+				},
+			},
+		},
+		Transitions: []TransitionConfig{
+			CommandTransitionConfig{
+				To:      "celebrate",
+				Command: "say ${winner} won",
+			},
+			KillTransitionConfig{
+				To: "quit",
+			},
+		},
+		Variables: map[string]string{
+			"team": "red",
+		},
+	}
+
+	return
+}
+
+func mustParseCondition(
+	source string,
+) *Condition {
+	condition, err := ParseCondition(source)
+	if err != nil {
+		panic(err)
+	}
+	return condition
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"time"
+)
+
+/*
+counter is a runner counter, it remembers its changes for as long as a
+threshold event might look back on them
+*/
+type counter struct {
+	value   int
+	changes []counterChange
+}
+
+type counterChange struct {
+	time  time.Time
+	delta int
+}
+
+// add changes the counter, changes older than keep are forgotten
+func (counter *counter) add(
+	delta int,
+	now time.Time,
+	keep time.Duration,
+) {
+	counter.value += delta
+
+	if keep <= 0 {
+		return
+	}
+	recent := counter.changes[:0]
+	for _, change := range counter.changes {
+		if now.Sub(change.time) < keep {
+			recent = append(recent, change)
+		}
+	}
+	counter.changes = append(recent, counterChange{now, delta})
+}
+
+func (counter *counter) reset() {
+	counter.value = 0
+	counter.changes = nil
+}
+
+/*
+count returns the value of the counter, or the sum of the changes within the
+window if there is one
+*/
+func (counter *counter) count(
+	window time.Duration,
+	now time.Time,
+) (
+	value int,
+) {
+	if window <= 0 {
+		value = counter.value
+		return
+	}
+
+	for _, change := range counter.changes {
+		if now.Sub(change.time) < window {
+			value += change.delta
+		}
+	}
+	return
+}
+
+// counterWindow returns the largest window of all threshold events
+func counterWindow(
+	config *Config,
+) (
+	window time.Duration,
+) {
+	for _, stateConfig := range config.States {
+		for _, eventConfigObject := range stateConfig.Events {
+			if eventConfig, ok := eventConfigObject.(ThresholdEventConfig); ok {
+				if eventConfig.Window > window {
+					window = eventConfig.Window
+				}
+			}
+		}
+	}
+	return
+}
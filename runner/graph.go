@@ -0,0 +1,336 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+WriteDot writes the state machine as a Graphviz DOT digraph. Every event is
+an edge labelled with the event, transitions are listed on the edges they
+apply to.
+*/
+func WriteDot(
+	writer io.Writer,
+	config *Config,
+) (
+	err error,
+) {
+	edges := graphEdges(config)
+
+	lines := []string{
+		"digraph script {",
+		"  rankdir=LR;",
+		"  __start [shape=point];",
+		fmt.Sprintf("  __start -> %s;", dotID(config.InitialState)),
+	}
+	for _, state := range graphStates(config, edges) {
+		shape := "ellipse"
+		if config.IsFinal(state) {
+			shape = "doublecircle"
+		}
+		lines = append(lines, fmt.Sprintf(
+			"  %s [shape=%s];",
+			dotID(state),
+			shape,
+		))
+	}
+	for _, edge := range edges {
+		lines = append(lines, fmt.Sprintf(
+			"  %s -> %s [label=%s];",
+			dotID(edge.from),
+			dotID(edge.to),
+			dotID(strings.Join(edge.labels(), "\n")),
+		))
+	}
+	lines = append(lines, "}")
+
+	_, err = io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+WriteMermaid writes the state machine as a Mermaid state diagram
+*/
+func WriteMermaid(
+	writer io.Writer,
+	config *Config,
+) (
+	err error,
+) {
+	edges := graphEdges(config)
+
+	lines := []string{
+		"stateDiagram-v2",
+		fmt.Sprintf("  [*] --> %s", mermaidID(config.InitialState)),
+	}
+	for _, state := range graphStates(config, edges) {
+		if mermaidID(state) != state {
+			lines = append(lines, fmt.Sprintf(
+				"  state %q as %s",
+				state,
+				mermaidID(state),
+			))
+		}
+	}
+	for _, edge := range edges {
+		lines = append(lines, fmt.Sprintf(
+			"  %s --> %s : %s",
+			mermaidID(edge.from),
+			mermaidID(edge.to),
+			mermaidLabel(strings.Join(edge.labels(), "<br/>")),
+		))
+	}
+	for _, state := range graphStates(config, edges) {
+		if config.IsFinal(state) {
+			lines = append(lines, fmt.Sprintf("  %s --> [*]", mermaidID(state)))
+		}
+	}
+
+	_, err = io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// graphEdge is an event that changes from one state to another
+type graphEdge struct {
+	from    string
+	to      string
+	event   string
+	actions []string
+}
+
+func (edge graphEdge) labels() (
+	labels []string,
+) {
+	labels = append(labels, edge.event)
+	for _, action := range edge.actions {
+		labels = append(labels, "/ "+action)
+	}
+	return
+}
+
+// graphEdges returns every event of every state, ordered by state
+func graphEdges(
+	config *Config,
+) (
+	edges []graphEdge,
+) {
+	stateNames := make([]string, 0, len(config.States))
+	for state := range config.States {
+		stateNames = append(stateNames, state)
+	}
+	sort.Strings(stateNames)
+
+	for _, state := range stateNames {
+		// a composite state is entered through its initial state
+		if initialState := config.States[state].InitialState; initialState != "" {
+			edges = append(edges, graphEdge{
+				from:  state,
+				to:    initialState,
+				event: "initial",
+			})
+		}
+
+		for _, eventConfig := range config.States[state].Events {
+			nextState := eventNextState(eventConfig)
+			if nextState == "" {
+				continue
+			}
+
+			edge := graphEdge{
+				from:  state,
+				to:    nextState,
+				event: eventLabel(eventConfig),
+			}
+			exited, entered, leafState := config.changeStates(state, nextState)
+			if leafState != state {
+				for _, action := range transition(exited, entered, config, nil) {
+					edge.actions = append(edge.actions, actionLabel(action))
+				}
+			}
+			edges = append(edges, edge)
+		}
+	}
+
+	return
+}
+
+// graphStates returns all states that are configured or an edge points to
+func graphStates(
+	config *Config,
+	edges []graphEdge,
+) (
+	states []string,
+) {
+	seen := make(map[string]bool)
+	add := func(state string) {
+		if state != "" && !seen[state] {
+			seen[state] = true
+			states = append(states, state)
+		}
+	}
+
+	add(config.InitialState)
+	for _, edge := range edges {
+		add(edge.from)
+		add(edge.to)
+	}
+
+	return
+}
+
+func eventLabel(
+	eventConfigUnknown EventConfig,
+) (
+	label string,
+) {
+	switch eventConfig := eventConfigUnknown.(type) {
+	case LiteralEventConfig:
+		label = fmt.Sprintf("literal %q", eventConfig.Value)
+
+	case RegexEventConfig:
+		label = fmt.Sprintf("regex /%s/", eventConfig.Regexp.String())
+
+	case TimerEventConfig:
+		label = fmt.Sprintf("timer %v", eventConfig.Interval)
+
+	case IdleEventConfig:
+		label = fmt.Sprintf("idle %v", eventConfig.Interval)
+		if eventConfig.Regexp != nil {
+			label += fmt.Sprintf(" /%s/", eventConfig.Regexp.String())
+		}
+
+	case ThresholdEventConfig:
+		label = fmt.Sprintf("threshold %s >= %d", eventConfig.Counter, eventConfig.Value)
+		if eventConfig.Window > 0 {
+			label += fmt.Sprintf(" in %v", eventConfig.Window)
+		}
+
+	case ExitEventConfig:
+		label = "exit"
+		if eventConfig.Code != nil {
+			label += fmt.Sprintf(" %d", *eventConfig.Code)
+		}
+		if eventConfig.Signal != nil {
+			label += fmt.Sprintf(" %v", eventConfig.Signal)
+		}
+	}
+
+	if when := eventCondition(eventConfigUnknown); when != nil {
+		label += " when " + when.String()
+	}
+
+	return
+}
+
+// eventCondition returns the condition of an event config, if any
+func eventCondition(
+	eventConfigUnknown EventConfig,
+) (
+	when *Condition,
+) {
+	switch eventConfig := eventConfigUnknown.(type) {
+	case LiteralEventConfig:
+		when = eventConfig.When
+
+	case RegexEventConfig:
+		when = eventConfig.When
+
+	case TimerEventConfig:
+		when = eventConfig.When
+
+	case IdleEventConfig:
+		when = eventConfig.When
+	}
+
+	return
+}
+
+func actionLabel(
+	actionUnknown Action,
+) (
+	label string,
+) {
+	switch action := actionUnknown.(type) {
+	case CommandAction:
+		commands := strings.Split(strings.TrimSpace(action.Command), "\n")
+		label = "command " + commands[0]
+		if len(commands) > 1 {
+			label += fmt.Sprintf(" (+%d)", len(commands)-1)
+		}
+
+	case SignalAction:
+		label = fmt.Sprintf("signal %v", action.Signal)
+
+	case KillAction:
+		label = "kill"
+
+	case WaitAction:
+		label = fmt.Sprintf("wait %v", action.Interval)
+
+	case HTTPAction:
+		label = "http " + action.URL
+
+	case ExecAction:
+		label = "exec " + action.Path
+
+	case ShutdownAction:
+		label = "shutdown"
+		if action.Timeout > 0 {
+			label += " " + action.Timeout.Round(time.Millisecond).String()
+		}
+	}
+
+	return
+}
+
+// dotID quotes a string so it can be used as an id or label in DOT
+func dotID(
+	value string,
+) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return `"` + value + `"`
+}
+
+// mermaidID makes a state name usable as a mermaid id
+func mermaidID(
+	state string,
+) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' ||
+			(r >= 'a' && r <= 'z') ||
+			(r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, state)
+}
+
+// mermaidLabelReplacer escapes characters that have a meaning in mermaid
+var mermaidLabelReplacer = strings.NewReplacer(
+	"#", "#35;",
+	":", "#58;",
+	";", "#59;",
+	`"`, "#quot;",
+)
+
+// mermaidLabel escapes a label for mermaid
+func mermaidLabel(
+	label string,
+) string {
+	return mermaidLabelReplacer.Replace(label)
+}
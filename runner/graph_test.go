@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDot(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var buffer bytes.Buffer
+	err = WriteDot(&buffer, makeLightTestConfig())
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, `digraph script {
+  rankdir=LR;
+  __start [shape=point];
+  __start -> "Off";
+  "Off" [shape=ellipse];
+  "On" [shape=ellipse];
+  "Off" -> "On" [label="regex /^SwitchOn$/\n/ command DoSwitchOn"];
+  "On" -> "Off" [label="literal \"SwitchOff\"\n/ command DoSwitchOff"];
+  "On" -> "Off" [label="timer 1s\n/ command DoSwitchOff"];
+}
+`, buffer.String())
+}
+
+func TestWriteMermaid(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var buffer bytes.Buffer
+	err = WriteMermaid(&buffer, makeMatchTestConfig())
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, `stateDiagram-v2
+  [*] --> idle
+  end --> quit : timer 10s<br/>/ kill
+  idle --> playing : literal #quot;Match is LIVE#quot;
+  idle --> quit : timer 15m0s<br/>/ kill
+  playing --> end : literal #quot;Match ended#quot;<br/>/ command say bye
+  quit --> [*]
+`, buffer.String())
+}
+
+func TestWriteMermaidNested(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var buffer bytes.Buffer
+	err = WriteMermaid(&buffer, makeNestedTestConfig())
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, `stateDiagram-v2
+  [*] --> match
+  end --> quit : timer 10s<br/>/ kill
+  live --> end : literal #quot;quit#quot;<br/>/ command tv_stoprecord
+  live --> match : literal #quot;restart#quot;<br/>/ command tv_stoprecord<br/>/ command echo Match...
+  match --> warmup : initial
+  match --> quit : literal #quot;quit#quot;<br/>/ command tv_stoprecord<br/>/ kill
+  match --> quit : timer 1h0m0s<br/>/ command tv_stoprecord<br/>/ kill
+  warmup --> live : literal #quot;Match is LIVE#quot;<br/>/ command say live
+  quit --> [*]
+`, buffer.String())
+}
@@ -0,0 +1,93 @@
+package runner
+
+/*
+LeafState returns the state the runner is in after changing to state. A
+composite state, a state that is the parent of other states, is entered
+through its initial state.
+*/
+func (config *Config) LeafState(
+	state string,
+) string {
+	seen := make(map[string]bool)
+	for !seen[state] {
+		seen[state] = true
+
+		stateConfig, ok := config.States[state]
+		if !ok || stateConfig.InitialState == "" {
+			break
+		}
+		state = stateConfig.InitialState
+	}
+	return state
+}
+
+// stateChain returns state and all of its parents, innermost first
+func (config *Config) stateChain(
+	state string,
+) (
+	chain []string,
+) {
+	seen := make(map[string]bool)
+	for state != "" && !seen[state] {
+		seen[state] = true
+		chain = append(chain, state)
+		state = config.States[state].Parent
+	}
+	return
+}
+
+/*
+changeStates returns the states that are exited, innermost first, and the
+states that are entered, outermost first, when the runner changes from the
+leaf prevState to target. Parents of target that are already active are not
+exited, everything from target down is. nextState is the leaf that is
+entered.
+*/
+func (config *Config) changeStates(
+	prevState string,
+	target string,
+) (
+	exited []string,
+	entered []string,
+	nextState string,
+) {
+	nextState = config.LeafState(target)
+
+	active := make(map[string]bool)
+	for _, state := range config.stateChain(prevState) {
+		active[state] = true
+	}
+	kept := make(map[string]bool)
+	for _, state := range config.stateChain(target)[1:] {
+		if active[state] {
+			kept[state] = true
+		}
+	}
+
+	for _, state := range config.stateChain(prevState) {
+		if !kept[state] {
+			exited = append(exited, state)
+		}
+	}
+	chain := config.stateChain(nextState)
+	for index := len(chain) - 1; index >= 0; index-- {
+		if !kept[chain[index]] {
+			entered = append(entered, chain[index])
+		}
+	}
+
+	return
+}
+
+// containsState tells if state is one of states
+func containsState(
+	states []string,
+	state string,
+) bool {
+	for _, candidate := range states {
+		if candidate == state {
+			return true
+		}
+	}
+	return false
+}
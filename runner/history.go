@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"time"
+)
+
+/*
+History records the states a runner visited and the variables it captured.
+It is built from the state changes of a runner, so it can follow a runner
+that runs in its own routine.
+*/
+type History struct {
+	visits    []StateVisit
+	variables map[string]string
+	result    map[string]string
+}
+
+/*
+StateVisit is a visit of a state, Exit is zero while the runner is still in
+the state
+*/
+type StateVisit struct {
+	State string
+	Enter time.Time
+	Exit  time.Time
+}
+
+/*
+NewHistory creates an empty History
+*/
+func NewHistory() *History {
+	return &History{
+		variables: make(map[string]string),
+	}
+}
+
+/*
+Enter records that the runner is in state from now on, entering the state
+the runner is in already does nothing. Use it when a runner starts.
+*/
+func (history *History) Enter(
+	state string,
+	now time.Time,
+) {
+	if count := len(history.visits); count > 0 {
+		last := &history.visits[count-1]
+		if last.State == state && last.Exit.IsZero() {
+			return
+		}
+		if last.Exit.IsZero() {
+			last.Exit = now
+		}
+	}
+
+	history.visits = append(history.visits, StateVisit{
+		State: state,
+		Enter: now,
+	})
+	history.result = nil
+}
+
+/*
+Add records a state change
+*/
+func (history *History) Add(
+	stateChange StateChange,
+) {
+	history.Enter(stateChange.NextState, stateChange.Time)
+
+	for name, value := range stateChange.Variables {
+		history.variables[name] = value
+	}
+	history.result = stateChange.Result
+}
+
+/*
+State returns the state the runner is in, or an empty string if nothing is
+recorded yet
+*/
+func (history *History) State() string {
+	if len(history.visits) == 0 {
+		return ""
+	}
+	return history.visits[len(history.visits)-1].State
+}
+
+/*
+Visits returns every visit in order
+*/
+func (history *History) Visits() []StateVisit {
+	return append([]StateVisit(nil), history.visits...)
+}
+
+/*
+Variables returns the captured variables as of the last state change
+*/
+func (history *History) Variables() map[string]string {
+	variables := make(map[string]string)
+	for name, value := range history.variables {
+		variables[name] = value
+	}
+	return variables
+}
+
+/*
+Result returns the result of the state the runner is in, if it is final
+*/
+func (history *History) Result() map[string]string {
+	return history.result
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(test *testing.T) {
+	config := makeFinalTestConfig()
+	// only captured variables are recorded
+	config.Variables = map[string]string{"rcon_password": "secret"}
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"Team red won", start.Add(time.Minute * 30)},
+		},
+		start.Add(time.Hour),
+	)
+
+	history := NewHistory()
+	history.Enter("playing", start)
+	// entering the same state again does nothing
+	history.Enter("playing", start.Add(time.Minute))
+	for _, stateChange := range stateChanges {
+		history.Add(stateChange)
+	}
+
+	assert.Equal(test, "completed", history.State())
+	assert.Equal(test, []StateVisit{
+		{"playing", start, start.Add(time.Minute * 30)},
+		{"completed", start.Add(time.Minute * 30), time.Time{}},
+	}, history.Visits())
+	assert.Equal(test, map[string]string{"winner": "red"}, history.Variables())
+	assert.Equal(test, map[string]string{
+		"outcome": "completed",
+		"winner":  "red",
+	}, history.Result())
+}
@@ -1,210 +1,412 @@
 package runner
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/Gameye/igniter-shell-go/utils"
 )
 
 /*
 StateChange contains information on a changes state
 */
-type StateChange interface{}
+type StateChange struct {
+	PrevState string
+	NextState string
+	Event     string
+	Line      string
+	Time      time.Time
+	Actions   []Action
+	// Result is the result of the next state if it is final
+	Result map[string]string
+	// Variables are the variables set by captures and counters after the
+	// change, the variables of the config are left out
+	Variables map[string]string
+}
 
 /*
-CommandStateChange sends commands to the process
+Action is an action that should be performed when changing state
 */
-type CommandStateChange struct {
-	NextState string
-	Command   string
+type Action interface{}
+
+/*
+CommandAction sends commands to the process
+*/
+type CommandAction struct {
+	Command string
 }
 
 /*
-SignalStateChange sends a signal to the process
+SignalAction sends a signal to the process
 */
-type SignalStateChange struct {
-	NextState string
-	Signal    os.Signal
+type SignalAction struct {
+	Signal os.Signal
 }
 
 /*
-KillStateChange kills the process
+KillAction kills the process
 */
-type KillStateChange struct {
-	NextState string
+type KillAction struct {
+}
+
+/*
+ShutdownAction stops the process gracefully with a command or a signal and
+escalates if the process does not exit within the timeout
+*/
+type ShutdownAction struct {
+	Command string
+	Signal  os.Signal
+	Timeout time.Duration
+}
+
+/*
+HTTPAction posts Body, a json document, to URL without holding up the actions
+that follow
+*/
+type HTTPAction struct {
+	URL     string
+	Body    []byte
+	Retries int
+	Timeout time.Duration
+}
+
+/*
+ExecAction runs a program without holding up the actions that follow. Env is
+added to the environment, if Lines is true the output of the program is
+handled like output of the process.
+*/
+type ExecAction struct {
+	Path    string
+	Args    []string
+	Env     map[string]string
+	Lines   bool
+	Timeout time.Duration
+}
+
+/*
+WaitAction delays the actions that follow
+*/
+type WaitAction struct {
+	Interval time.Duration
+}
+
+/*
+ExitStatus is how the process exited, Signal is set if the process was
+terminated by a signal
+*/
+type ExitStatus struct {
+	Code   int
+	Signal os.Signal
 }
 
 /*
-Run runs a new Runner
+String describes the status like os.ProcessState does
+*/
+func (status ExitStatus) String() string {
+	if status.Signal != nil {
+		return fmt.Sprintf("signal: %v", status.Signal)
+	}
+	return fmt.Sprintf("exit status %d", status.Code)
+}
+
+/*
+Run runs a new Runner with the real clock. The exit of the process is the
+last thing the runner handles, exitChannel may be nil.
 */
 func Run(
 	config *Config,
 	actionChannel <-chan string,
+	exitChannel <-chan ExitStatus,
 ) <-chan StateChange {
 	changeChannel := make(chan StateChange)
 
 	go func() {
 		defer close(changeChannel)
 
-		state := config.InitialState
-		for {
-			start := time.Now()
-
-			// find state config
-			stateConfig, hasStateConfig := config.States[state]
-			if !hasStateConfig {
-				return
+		runner := NewRunner(config, time.Now())
+		if stateChange, ok := runner.Start(time.Now()); ok {
+			changeChannel <- stateChange
+		}
+		for !runner.Done() {
+			var timer *time.Timer
+			var timerChannel <-chan time.Time
+			deadline, hasDeadline := runner.Deadline()
+			if hasDeadline {
+				timer = time.NewTimer(time.Until(deadline))
+				timerChannel = timer.C
 			}
 
-			// setup timer event
-			interval := time.Duration(1<<63 - 1) // maxDuration from time.go:624
-			for _, eventConfigObject := range stateConfig.Events {
-				switch eventConfig := eventConfigObject.(type) {
+			var stateChange StateChange
+			var changed bool
+			select {
+			case now := <-timerChannel:
+				stateChange, changed = runner.HandleTime(now)
 
-				case TimerEventConfig:
-					// we only use the first timer
-					if time.Duration(eventConfig.Interval) < interval {
-						interval = time.Duration(eventConfig.Interval)
+			case action, more := <-actionChannel:
+				if !more {
+					if timer != nil {
+						timer.Stop()
 					}
+					return
 				}
-			}
-			timer := time.NewTimer(interval)
-
-			nextState := ""
-			for nextState == "" {
-				select {
-				case now := <-timer.C:
-					for _, eventConfigObject := range stateConfig.Events {
-						switch eventConfig := eventConfigObject.(type) {
-
-						case TimerEventConfig:
-							nextState = handleTimerEvent(&eventConfig, now.Sub(start))
-							if nextState != "" {
-								break
-							}
-						}
-					}
 
-				case action, more := <-actionChannel:
-					if !more {
-						return
-					}
+				stateChange, changed = runner.HandleLine(action, time.Now())
 
-					action = strings.TrimSpace(action)
-
-				loop:
-					for _, eventConfigObject := range stateConfig.Events {
-						switch eventConfig := eventConfigObject.(type) {
-
-						case LiteralEventConfig:
-							nextState = handleLiteralEvent(&eventConfig, action)
-							if nextState != "" {
-								break loop
-							}
-
-						case RegexEventConfig:
-							nextState = handleRegexEvent(&eventConfig, action)
-							if nextState != "" {
-								break loop
-							}
+			case status := <-exitChannel:
+				if timer != nil {
+					timer.Stop()
+				}
 
+				// lines that are waiting were printed before the exit
+				for pending := true; pending; {
+					select {
+					case action, more := <-actionChannel:
+						if !more {
+							pending = false
+							break
 						}
+						stateChange, changed = runner.HandleLine(action, time.Now())
+						if changed {
+							changeChannel <- stateChange
+						}
+					default:
+						pending = false
 					}
-
 				}
 
+				stateChange, changed = runner.HandleExit(status, time.Now())
+				if changed {
+					changeChannel <- stateChange
+				}
+				return
 			}
 
-			timer.Stop()
-
-			if nextState != state {
-				pushState(
-					nextState,
-					state,
-					config,
-					changeChannel,
-				)
-				state = nextState
+			if timer != nil {
+				timer.Stop()
 			}
 
+			if changed {
+				changeChannel <- stateChange
+			}
 		}
 	}()
 
 	return changeChannel
 }
 
-func pushState(
-	nextState string,
-	prevState string,
+/*
+transition returns the exit actions of the exited states, the actions of
+every transition that matches, in the order they are configured, and the
+enter actions of the entered states. A transition matches if its from state
+is exited and its to state is entered.
+*/
+func transition(
+	exited []string,
+	entered []string,
 	config *Config,
-	changeChannel chan<- StateChange,
+	variables map[string]string,
+) (
+	actions []Action,
 ) {
-	stateChange := transition(
-		nextState,
-		prevState,
-		config,
-	)
-	changeChannel <- stateChange
+	for _, state := range exited {
+		for _, actionConfig := range config.States[state].OnExit {
+			actions = appendActions(actions, actionConfig, variables)
+		}
+	}
+
+	for _, transitionConfig := range config.Transitions {
+		from, to := transitionStates(transitionConfig)
+		if (from == "" || containsState(exited, from)) &&
+			(to == "" || containsState(entered, to)) {
+			actions = appendActions(actions, transitionConfig, variables)
+		}
+	}
+
+	actions = append(actions, enterActions(entered, config, variables)...)
+
+	return
 }
 
-func transition(
-	nextState string,
-	prevState string,
+// enterActions returns the enter actions of the entered states
+func enterActions(
+	entered []string,
 	config *Config,
+	variables map[string]string,
 ) (
-	stateChange StateChange,
+	actions []Action,
 ) {
-	for _, transitionConfigUnknown := range config.Transitions {
-		switch transitionConfig := transitionConfigUnknown.(type) {
-		case CommandTransitionConfig:
-			if (transitionConfig.From == prevState || transitionConfig.From == "") &&
-				(transitionConfig.To == nextState || transitionConfig.To == "") {
-				stateChange = CommandStateChange{
-					NextState: nextState,
-					Command:   transitionConfig.Command,
-				}
-				break
-			}
+	for _, state := range entered {
+		for _, actionConfig := range config.States[state].OnEnter {
+			actions = appendActions(actions, actionConfig, variables)
+		}
+	}
 
-		case SignalTransitionConfig:
-			if (transitionConfig.From == prevState || transitionConfig.From == "") &&
-				(transitionConfig.To == nextState || transitionConfig.To == "") {
-				stateChange = SignalStateChange{
-					NextState: nextState,
-					Signal:    transitionConfig.Signal,
-				}
-				break
-			}
+	return
+}
 
-		case KillTransitionConfig:
-			if (transitionConfig.From == prevState || transitionConfig.From == "") &&
-				(transitionConfig.To == nextState || transitionConfig.To == "") {
-				stateChange = KillStateChange{
-					NextState: nextState,
-				}
-				break
-			}
-		}
+func transitionStates(
+	transitionConfigUnknown TransitionConfig,
+) (
+	from string,
+	to string,
+) {
+	switch transitionConfig := transitionConfigUnknown.(type) {
+	case CommandTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case SignalTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case KillTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
 
+	case WaitTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case ShutdownTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case SequenceTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case HTTPTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
+
+	case ExecTransitionConfig:
+		from, to = transitionConfig.From, transitionConfig.To
 	}
 
 	return
 }
 
+func appendActions(
+	actions []Action,
+	transitionConfigUnknown TransitionConfig,
+	variables map[string]string,
+) []Action {
+	switch transitionConfig := transitionConfigUnknown.(type) {
+	case CommandTransitionConfig:
+		actions = append(actions, CommandAction{
+			Command: utils.RenderTemplate(
+				transitionConfig.Command,
+				variables,
+			),
+		})
+
+	case SignalTransitionConfig:
+		actions = append(actions, SignalAction{
+			Signal: transitionConfig.Signal,
+		})
+
+	case KillTransitionConfig:
+		actions = append(actions, KillAction{})
+
+	case WaitTransitionConfig:
+		actions = append(actions, WaitAction{
+			Interval: transitionConfig.Interval,
+		})
+
+	case ShutdownTransitionConfig:
+		actions = append(actions, ShutdownAction{
+			Command: utils.RenderTemplate(
+				transitionConfig.Command,
+				variables,
+			),
+			Signal:  transitionConfig.Signal,
+			Timeout: transitionConfig.Timeout,
+		})
+
+	case SequenceTransitionConfig:
+		for _, actionConfig := range transitionConfig.Actions {
+			actions = appendActions(actions, actionConfig, variables)
+		}
+
+	case HTTPTransitionConfig:
+		var body []byte
+		if transitionConfig.Body != nil {
+			// the body was decoded from json, so it can always be encoded
+			body, _ = json.Marshal(renderBody(transitionConfig.Body, variables))
+		}
+		actions = append(actions, HTTPAction{
+			URL: utils.RenderTemplate(
+				transitionConfig.URL,
+				variables,
+			),
+			Body:    body,
+			Retries: transitionConfig.Retries,
+			Timeout: transitionConfig.Timeout,
+		})
+
+	case ExecTransitionConfig:
+		args := make([]string, len(transitionConfig.Args))
+		for index, arg := range transitionConfig.Args {
+			args[index] = utils.RenderTemplate(arg, variables)
+		}
+		env := make(map[string]string)
+		for name, value := range transitionConfig.Env {
+			env[name] = utils.RenderTemplate(value, variables)
+		}
+		actions = append(actions, ExecAction{
+			Path: utils.RenderTemplate(
+				transitionConfig.Path,
+				variables,
+			),
+			Args:    args,
+			Env:     env,
+			Lines:   transitionConfig.Lines,
+			Timeout: transitionConfig.Timeout,
+		})
+	}
+
+	return actions
+}
+
+// renderBody renders every string in a decoded json value
+func renderBody(
+	value interface{},
+	variables map[string]string,
+) interface{} {
+	switch typed := value.(type) {
+	case string:
+		return utils.RenderTemplate(typed, variables)
+
+	case []interface{}:
+		rendered := make([]interface{}, len(typed))
+		for index, item := range typed {
+			rendered[index] = renderBody(item, variables)
+		}
+		return rendered
+
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			rendered[key] = renderBody(item, variables)
+		}
+		return rendered
+	}
+
+	return value
+}
+
 func handleLiteralEvent(
 	eventConfig *LiteralEventConfig,
 	action string,
+	variables map[string]string,
 ) (
 	nextState string,
+	matched bool,
 ) {
 	if eventConfig.IgnoreCase {
-		if strings.ToLower(eventConfig.Value) == strings.ToLower(action) {
-			nextState = eventConfig.NextState
-		}
+		matched = strings.ToLower(eventConfig.Value) == strings.ToLower(action)
 	} else {
-		if eventConfig.Value == action {
-			nextState = eventConfig.NextState
-		}
+		matched = eventConfig.Value == action
+	}
+	matched = matched && eventConfig.When.Holds(variables)
+	if matched {
+		nextState = eventConfig.NextState
 	}
 	return
 }
@@ -212,23 +414,99 @@ func handleLiteralEvent(
 func handleRegexEvent(
 	eventConfig *RegexEventConfig,
 	action string,
+	variables map[string]string,
 ) (
 	nextState string,
+	matched bool,
 ) {
-	if eventConfig.Regexp.MatchString(action) {
-		nextState = eventConfig.NextState
+	match := eventConfig.Regexp.FindStringSubmatch(action)
+	if match == nil {
+		return
 	}
+
+	// named groups become variables
+	captures := make(map[string]string)
+	for index, name := range eventConfig.Regexp.SubexpNames() {
+		if name != "" {
+			captures[name] = match[index]
+		}
+	}
+
+	// the condition may use the captures, that are only kept if it holds
+	if eventConfig.When != nil {
+		guardVariables := make(map[string]string)
+		for name, value := range variables {
+			guardVariables[name] = value
+		}
+		for name, value := range captures {
+			guardVariables[name] = value
+		}
+		if !eventConfig.When.Holds(guardVariables) {
+			return
+		}
+	}
+
+	for name, value := range captures {
+		variables[name] = value
+	}
+
+	nextState = eventConfig.NextState
+	matched = true
 	return
 }
 
 func handleTimerEvent(
 	eventConfig *TimerEventConfig,
 	interval time.Duration,
+	variables map[string]string,
+) (
+	nextState string,
+) {
+	if interval >= eventConfig.Interval && eventConfig.When.Holds(variables) {
+		nextState = eventConfig.NextState
+	}
+	return
+}
+
+func handleIdleEvent(
+	eventConfig *IdleEventConfig,
+	interval time.Duration,
+	variables map[string]string,
 ) (
 	nextState string,
 ) {
-	if interval > time.Duration(eventConfig.Interval) {
+	if interval >= eventConfig.Interval && eventConfig.When.Holds(variables) {
 		nextState = eventConfig.NextState
 	}
 	return
 }
+
+func handleThresholdEvent(
+	eventConfig *ThresholdEventConfig,
+	count int,
+) (
+	nextState string,
+) {
+	if count >= eventConfig.Value {
+		nextState = eventConfig.NextState
+	}
+	return
+}
+
+func handleExitEvent(
+	eventConfig *ExitEventConfig,
+	status ExitStatus,
+) (
+	nextState string,
+) {
+	if eventConfig.Code != nil &&
+		(status.Signal != nil || status.Code != *eventConfig.Code) {
+		return
+	}
+	if eventConfig.Signal != nil && status.Signal != eventConfig.Signal {
+		return
+	}
+
+	nextState = eventConfig.NextState
+	return
+}
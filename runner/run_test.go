@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"syscall"
 	"testing"
 	"time"
 
@@ -16,31 +17,38 @@ func TestLightRunner(test *testing.T) {
 	changeChannel := Run(
 		config,
 		actionChannel,
+		nil,
 	)
 
+	var stateChange StateChange
+
 	actionChannel <- "SwitchOn"
-	assert.Equal(test, CommandStateChange{
-		"On",
-		"DoSwitchOn",
-	}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "Off", stateChange.PrevState)
+	assert.Equal(test, "On", stateChange.NextState)
+	assert.Equal(test, "regex", stateChange.Event)
+	assert.Equal(test, "SwitchOn", stateChange.Line)
+	assert.Equal(test, []Action{CommandAction{"DoSwitchOn"}}, stateChange.Actions)
 
 	actionChannel <- "SwitchOff"
-	assert.Equal(test, CommandStateChange{
-		"Off",
-		"DoSwitchOff",
-	}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "On", stateChange.PrevState)
+	assert.Equal(test, "Off", stateChange.NextState)
+	assert.Equal(test, "literal", stateChange.Event)
+	assert.Equal(test, "SwitchOff", stateChange.Line)
+	assert.Equal(test, []Action{CommandAction{"DoSwitchOff"}}, stateChange.Actions)
 
 	actionChannel <- "SwitchOn"
-	assert.Equal(test, CommandStateChange{
-		"On",
-		"DoSwitchOn",
-	}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "On", stateChange.NextState)
+	assert.Equal(test, []Action{CommandAction{"DoSwitchOn"}}, stateChange.Actions)
 
 	time.Sleep(time.Second * 2)
-	assert.Equal(test, CommandStateChange{
-		"Off",
-		"DoSwitchOff",
-	}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "Off", stateChange.NextState)
+	assert.Equal(test, "timer", stateChange.Event)
+	assert.Equal(test, "", stateChange.Line)
+	assert.Equal(test, []Action{CommandAction{"DoSwitchOff"}}, stateChange.Actions)
 
 }
 
@@ -51,10 +59,12 @@ func TestAutoRunner(test *testing.T) {
 	defer close(actionChannel)
 
 	var timer *time.Timer
+	var stateChange StateChange
 
 	changeChannel := Run(
 		config,
 		actionChannel,
+		nil,
 	)
 
 	actionChannel <- "noop"
@@ -66,7 +76,9 @@ func TestAutoRunner(test *testing.T) {
 		return
 	}
 
-	assert.Equal(test, CommandStateChange{"On", "echo on"}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "On", stateChange.NextState)
+	assert.Equal(test, []Action{CommandAction{"echo on"}}, stateChange.Actions)
 
 	actionChannel <- "noop"
 	timer = time.NewTimer(time.Second * 1)
@@ -77,5 +89,338 @@ func TestAutoRunner(test *testing.T) {
 		return
 	}
 
-	assert.Equal(test, CommandStateChange{"Off", "echo off"}, <-changeChannel)
+	stateChange = <-changeChannel
+	assert.Equal(test, "Off", stateChange.NextState)
+	assert.Equal(test, []Action{CommandAction{"echo off"}}, stateChange.Actions)
+}
+
+func TestCaptureRunner(test *testing.T) {
+	config := makeCaptureTestConfig()
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	actionChannel <- "elmerbulthuis connected"
+	assert.Equal(test, []Action{CommandAction{"say hello elmerbulthuis"}}, (<-changeChannel).Actions)
+
+	actionChannel <- "greeted"
+	assert.Empty(test, (<-changeChannel).Actions)
+
+	actionChannel <- "luke connected"
+	assert.Equal(test, []Action{CommandAction{"say hello luke"}}, (<-changeChannel).Actions)
+}
+
+func TestSequenceRunner(test *testing.T) {
+	config := makeSequenceTestConfig()
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	actionChannel <- "quit"
+	assert.Equal(test, []Action{
+		CommandAction{"say bye"},
+		CommandAction{"quit"},
+		WaitAction{time.Second * 5},
+		SignalAction{syscall.SIGTERM},
+		KillAction{},
+	}, (<-changeChannel).Actions)
+}
+
+func TestEnterExitRunner(test *testing.T) {
+	config := makeEnterExitTestConfig()
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	// exit actions first, then transitions, then enter actions
+	actionChannel <- "Match is LIVE"
+	assert.Equal(test, []Action{
+		CommandAction{"say good luck"},
+		CommandAction{"tv_record match"},
+		CommandAction{"echo Playing..."},
+	}, (<-changeChannel).Actions)
+
+	actionChannel <- "Match ended"
+	assert.Equal(test, []Action{
+		CommandAction{"tv_stoprecord"},
+		CommandAction{"quit"},
+	}, (<-changeChannel).Actions)
+}
+
+func TestFinalRunner(test *testing.T) {
+	config := makeFinalTestConfig()
+
+	actionChannel := make(chan string, 2)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	actionChannel <- "Team red won"
+	actionChannel <- "Invalid token"
+	stateChange := <-changeChannel
+	assert.Equal(test, "completed", stateChange.NextState)
+	assert.Equal(test, []Action{CommandAction{"quit"}}, stateChange.Actions)
+	assert.Equal(test, map[string]string{
+		"outcome": "completed",
+		"winner":  "red",
+	}, stateChange.Result)
+
+	// the runner stops in a final state
+	_, more := <-changeChannel
+	assert.False(test, more)
+
+	exitCode, ok := config.ExitCode("completed")
+	assert.True(test, ok)
+	assert.Equal(test, 0, exitCode)
+
+	exitCode, ok = config.ExitCode("token_invalid")
+	assert.True(test, ok)
+	assert.Equal(test, 3, exitCode)
+
+	assert.True(test, config.IsFinal("token_invalid"))
+	assert.True(test, config.IsFinal("quit"))
+	assert.False(test, config.IsFinal("playing"))
+}
+
+func TestHTTPRunner(test *testing.T) {
+	config := makeHTTPTestConfig()
+	config.Variables = map[string]string{
+		"api":   "http://localhost:8080",
+		"match": "42",
+	}
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	actionChannel <- "Loading map de_dust2"
+	assert.Equal(test, []Action{
+		HTTPAction{
+			URL:     "http://localhost:8080/matches/42",
+			Body:    []byte(`{"map":"de_dust2","rounds":[1,"de_dust2"],"state":"playing"}`),
+			Retries: 3,
+			Timeout: time.Second * 2,
+		},
+	}, (<-changeChannel).Actions)
+}
+
+func TestExecRunner(test *testing.T) {
+	config := makeExecTestConfig()
+	config.Variables = map[string]string{
+		"match": "42",
+	}
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	actionChannel <- "Match ended"
+	assert.Equal(test, []Action{
+		ExecAction{
+			Path:    "/scripts/upload.sh",
+			Args:    []string{"--match", "42"},
+			Env:     map[string]string{"DEMO": "42.dem"},
+			Lines:   true,
+			Timeout: time.Second * 30,
+		},
+	}, (<-changeChannel).Actions)
+
+	// the output of the program is handled like output of the process
+	actionChannel <- "upload done"
+	assert.Equal(test, "quit", (<-changeChannel).NextState)
+}
+
+func TestNestedRunner(test *testing.T) {
+	config := makeNestedTestConfig()
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	// the match is entered through its initial state
+	actionChannel <- "Match is LIVE"
+	stateChange := <-changeChannel
+	assert.Equal(test, "warmup", stateChange.PrevState)
+	assert.Equal(test, "live", stateChange.NextState)
+	assert.Equal(test, []Action{CommandAction{"say live"}}, stateChange.Actions)
+
+	// changing to the composite state exits and enters it again
+	actionChannel <- "restart"
+	stateChange = <-changeChannel
+	assert.Equal(test, "warmup", stateChange.NextState)
+	assert.Equal(test, []Action{
+		CommandAction{"tv_stoprecord"},
+		CommandAction{"echo Match..."},
+	}, stateChange.Actions)
+
+	// events of the parent apply to the child
+	actionChannel <- "quit"
+	stateChange = <-changeChannel
+	assert.Equal(test, "quit", stateChange.NextState)
+	assert.Equal(test, []Action{
+		CommandAction{"tv_stoprecord"},
+		KillAction{},
+	}, stateChange.Actions)
+}
+
+func TestExitRunner(test *testing.T) {
+	config := makeExitTestConfig()
+
+	for _, testCase := range []struct {
+		status    ExitStatus
+		nextState string
+		line      string
+		exitCode  int
+	}{
+		{ExitStatus{Code: 0}, "finished", "exit status 0", 0},
+		{ExitStatus{Code: -1, Signal: syscall.SIGSEGV}, "crashed", "signal: segmentation fault", 2},
+		{ExitStatus{Code: 1}, "failed", "exit status 1", 3},
+	} {
+		actionChannel := make(chan string, 1)
+		exitChannel := make(chan ExitStatus, 1)
+
+		changeChannel := Run(
+			config,
+			actionChannel,
+			exitChannel,
+		)
+
+		exitChannel <- testCase.status
+		stateChange := <-changeChannel
+		assert.Equal(test, "playing", stateChange.PrevState)
+		assert.Equal(test, testCase.nextState, stateChange.NextState)
+		assert.Equal(test, "exit", stateChange.Event)
+		assert.Equal(test, testCase.line, stateChange.Line)
+
+		// the runner stops after the exit
+		_, more := <-changeChannel
+		assert.False(test, more)
+		close(actionChannel)
+
+		exitCode, ok := config.ExitCode(stateChange.NextState)
+		assert.True(test, ok)
+		assert.Equal(test, testCase.exitCode, exitCode)
+	}
+
+	_, ok := config.ExitCode("playing")
+	assert.False(test, ok)
+}
+
+func TestNestedTimerRunner(test *testing.T) {
+	// the timer of the parent changes to one of its own children
+	config := &Config{
+		InitialState: "match",
+		States: map[string]StateConfig{
+			"match": StateConfig{
+				InitialState: "warmup",
+				Events: []EventConfig{
+					TimerEventConfig{
+						Interval:  time.Minute,
+						NextState: "live",
+					},
+				},
+			},
+			"warmup": StateConfig{
+				Parent: "match",
+			},
+			"live": StateConfig{
+				Parent: "match",
+			},
+		},
+	}
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	runner := NewRunner(config, start)
+	deadline, ok := runner.Deadline()
+	assert.True(test, ok)
+	assert.Equal(test, start.Add(time.Minute), deadline)
+
+	stateChange, changed := runner.HandleTime(deadline)
+	assert.True(test, changed)
+	assert.Equal(test, "live", stateChange.NextState)
+
+	// the timer waits another interval instead of firing again right away
+	deadline, ok = runner.Deadline()
+	assert.True(test, ok)
+	assert.Equal(test, start.Add(time.Minute*2), deadline)
+}
+
+func TestStartRunner(test *testing.T) {
+	config := makeNestedTestConfig()
+	config.States["match"] = withOnEnter(config.States["match"], "tv_record match")
+	config.States["warmup"] = withOnEnter(config.States["warmup"], "say warmup")
+
+	actionChannel := make(chan string, 1)
+	defer close(actionChannel)
+
+	changeChannel := Run(
+		config,
+		actionChannel,
+		nil,
+	)
+
+	// the initial state and its parents are entered, outermost first
+	stateChange := <-changeChannel
+	assert.Equal(test, "", stateChange.PrevState)
+	assert.Equal(test, "warmup", stateChange.NextState)
+	assert.Equal(test, "start", stateChange.Event)
+	assert.Equal(test, []Action{
+		CommandAction{"tv_record match"},
+		CommandAction{"say warmup"},
+	}, stateChange.Actions)
+
+	actionChannel <- "Match is LIVE"
+	assert.Equal(test, "live", (<-changeChannel).NextState)
+
+	// without enter actions there is nothing to report
+	runner := NewRunner(makeNestedTestConfig(), time.Now())
+	_, ok := runner.Start(time.Now())
+	assert.False(test, ok)
+}
+
+func withOnEnter(
+	stateConfig StateConfig,
+	command string,
+) StateConfig {
+	stateConfig.OnEnter = append(stateConfig.OnEnter, CommandTransitionConfig{
+		Command: command,
+	})
+	return stateConfig
 }
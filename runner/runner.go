@@ -0,0 +1,454 @@
+package runner
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/utils"
+)
+
+/*
+Runner is the state machine. It does not keep time itself, the time is
+passed with every line and Deadline tells when the runner needs to be called
+again if there are no lines. This makes it possible to run with a virtual
+clock. Run drives a Runner with the real clock.
+
+The runner is always in a leaf state, the parents of that state are active
+too. Events are handled from the innermost to the outermost state.
+*/
+type Runner struct {
+	config    *Config
+	state     string
+	variables map[string]string
+	// captured holds the names of the variables set by captures and
+	// counters, as opposed to the variables of the config
+	captured map[string]bool
+	// eventStarts holds when every timer and idle event of every active
+	// state started waiting
+	eventStarts map[string][]time.Time
+	counters    map[string]*counter
+	// counterWindow is how long counters remember their changes
+	counterWindow time.Duration
+}
+
+/*
+NewRunner creates a Runner in the initial state
+*/
+func NewRunner(
+	config *Config,
+	now time.Time,
+) *Runner {
+	runner := &Runner{
+		config:        config,
+		state:         config.LeafState(config.InitialState),
+		variables:     make(map[string]string),
+		captured:      make(map[string]bool),
+		eventStarts:   make(map[string][]time.Time),
+		counters:      make(map[string]*counter),
+		counterWindow: counterWindow(config),
+	}
+	for name, value := range config.Variables {
+		runner.variables[name] = value
+	}
+	chain := config.stateChain(runner.state)
+	for index := len(chain) - 1; index >= 0; index-- {
+		runner.enter(chain[index], now)
+	}
+	return runner
+}
+
+/*
+Start returns the state change that enters the initial state, with the enter
+actions of that state and of its parents. The runner is in the initial state
+already, ok is false if there are no actions to perform.
+*/
+func (runner *Runner) Start(
+	now time.Time,
+) (
+	stateChange StateChange,
+	ok bool,
+) {
+	chain := runner.config.stateChain(runner.state)
+	entered := make([]string, 0, len(chain))
+	for index := len(chain) - 1; index >= 0; index-- {
+		entered = append(entered, chain[index])
+	}
+
+	actions := enterActions(entered, runner.config, runner.variables)
+	if len(actions) == 0 {
+		return
+	}
+
+	stateChange = StateChange{
+		NextState: runner.state,
+		Event:     "start",
+		Time:      now,
+		Actions:   actions,
+		Variables: make(map[string]string),
+	}
+	ok = true
+	return
+}
+
+/*
+State returns the current state
+*/
+func (runner *Runner) State() string {
+	return runner.state
+}
+
+/*
+Done tells if the runner stopped, this happens when the current state is
+final or not configured
+*/
+func (runner *Runner) Done() bool {
+	return runner.config.IsFinal(runner.state)
+}
+
+/*
+Deadline returns the time the first timer or idle event of the active states
+fires, if there is any
+*/
+func (runner *Runner) Deadline() (
+	deadline time.Time,
+	ok bool,
+) {
+	for _, state := range runner.config.stateChain(runner.state) {
+		for index, eventConfigObject := range runner.config.States[state].Events {
+			var eventDeadline time.Time
+			switch eventConfig := eventConfigObject.(type) {
+
+			case TimerEventConfig:
+				eventDeadline = runner.eventStarts[state][index].Add(eventConfig.Interval)
+
+			case IdleEventConfig:
+				eventDeadline = runner.eventStarts[state][index].Add(eventConfig.Interval)
+
+			default:
+				continue
+			}
+
+			if !ok || eventDeadline.Before(deadline) {
+				deadline = eventDeadline
+				ok = true
+			}
+		}
+	}
+
+	return
+}
+
+/*
+HandleTime handles the passing of time, changed is true if a timer or idle
+event caused a state change
+*/
+func (runner *Runner) HandleTime(
+	now time.Time,
+) (
+	stateChange StateChange,
+	changed bool,
+) {
+	nextState := ""
+	event := ""
+	var fired *time.Time
+loop:
+	for _, state := range runner.config.stateChain(runner.state) {
+		for index, eventConfigObject := range runner.config.States[state].Events {
+			switch eventConfig := eventConfigObject.(type) {
+
+			case TimerEventConfig:
+				nextState = handleTimerEvent(
+					&eventConfig,
+					now.Sub(runner.eventStarts[state][index]),
+					runner.variables,
+				)
+				if nextState != "" {
+					event = "timer"
+					fired = &runner.eventStarts[state][index]
+					break loop
+				}
+
+			case IdleEventConfig:
+				nextState = handleIdleEvent(
+					&eventConfig,
+					now.Sub(runner.eventStarts[state][index]),
+					runner.variables,
+				)
+				if nextState != "" {
+					event = "idle"
+					fired = &runner.eventStarts[state][index]
+					break loop
+				}
+			}
+		}
+	}
+
+	// events that are due but did not happen, wait another interval
+	if nextState == "" {
+		runner.rearm(now)
+	}
+
+	/*
+		the event that fired waits again, its state is not entered again
+		if the event changes to a state inside of it
+	*/
+	if fired != nil {
+		*fired = now
+	}
+
+	return runner.change(nextState, event, "", now)
+}
+
+/*
+HandleLine handles a line of output, changed is true if the line caused a
+state change
+*/
+func (runner *Runner) HandleLine(
+	action string,
+	now time.Time,
+) (
+	stateChange StateChange,
+	changed bool,
+) {
+	chain := runner.config.stateChain(runner.state)
+
+	action = strings.TrimSpace(action)
+
+	// the line ends the wait of idle events that it counts for
+	for _, state := range chain {
+		for index, eventConfigObject := range runner.config.States[state].Events {
+			if eventConfig, ok := eventConfigObject.(IdleEventConfig); ok {
+				if eventConfig.Regexp == nil || eventConfig.Regexp.MatchString(action) {
+					runner.eventStarts[state][index] = now
+				}
+			}
+		}
+	}
+
+	nextState := ""
+	event := ""
+loop:
+	for _, state := range chain {
+		for _, eventConfigObject := range runner.config.States[state].Events {
+			switch eventConfig := eventConfigObject.(type) {
+
+			case LiteralEventConfig:
+				var matched bool
+				nextState, matched = handleLiteralEvent(&eventConfig, action, runner.variables)
+				if matched {
+					runner.count(eventConfig.CounterConfig, now)
+				}
+				if nextState != "" {
+					event = "literal"
+					break loop
+				}
+
+			case RegexEventConfig:
+				var matched bool
+				nextState, matched = handleRegexEvent(&eventConfig, action, runner.variables)
+				if matched {
+					for _, name := range eventConfig.Regexp.SubexpNames() {
+						if name != "" {
+							runner.captured[name] = true
+						}
+					}
+					runner.count(eventConfig.CounterConfig, now)
+				}
+				if nextState != "" {
+					event = "regex"
+					break loop
+				}
+
+			case ThresholdEventConfig:
+				nextState = handleThresholdEvent(
+					&eventConfig,
+					runner.counter(eventConfig.Counter).count(eventConfig.Window, now),
+				)
+				if nextState != "" {
+					event = "threshold"
+					break loop
+				}
+
+			}
+		}
+	}
+
+	return runner.change(nextState, event, action, now)
+}
+
+/*
+HandleExit handles the exit of the process, changed is true if the exit
+caused a state change
+*/
+func (runner *Runner) HandleExit(
+	status ExitStatus,
+	now time.Time,
+) (
+	stateChange StateChange,
+	changed bool,
+) {
+	nextState := ""
+loop:
+	for _, state := range runner.config.stateChain(runner.state) {
+		for _, eventConfigObject := range runner.config.States[state].Events {
+			switch eventConfig := eventConfigObject.(type) {
+
+			case ExitEventConfig:
+				nextState = handleExitEvent(&eventConfig, status)
+				if nextState != "" {
+					break loop
+				}
+			}
+		}
+	}
+
+	return runner.change(nextState, "exit", status.String(), now)
+}
+
+/*
+change moves the runner to target, a leaf or a composite state. Changing to
+the current state restarts the timers without reporting a state change.
+*/
+func (runner *Runner) change(
+	target string,
+	event string,
+	line string,
+	now time.Time,
+) (
+	stateChange StateChange,
+	changed bool,
+) {
+	if target == "" {
+		return
+	}
+
+	prevState := runner.state
+	exited, entered, nextState := runner.config.changeStates(prevState, target)
+	runner.state = nextState
+	for _, state := range exited {
+		delete(runner.eventStarts, state)
+	}
+	for _, state := range entered {
+		runner.enter(state, now)
+	}
+
+	if nextState == prevState {
+		return
+	}
+
+	stateChange = StateChange{
+		PrevState: prevState,
+		NextState: nextState,
+		Event:     event,
+		Line:      line,
+		Time:      now,
+		Actions: transition(
+			exited,
+			entered,
+			runner.config,
+			runner.variables,
+		),
+		Result:    runner.result(nextState),
+		Variables: make(map[string]string),
+	}
+	for name := range runner.captured {
+		stateChange.Variables[name] = runner.variables[name]
+	}
+	changed = true
+
+	return
+}
+
+// enter starts the timers and idle events of state
+func (runner *Runner) enter(
+	state string,
+	now time.Time,
+) {
+	events := runner.config.States[state].Events
+	eventStarts := make([]time.Time, len(events))
+	for index := range eventStarts {
+		eventStarts[index] = now
+	}
+	runner.eventStarts[state] = eventStarts
+}
+
+// result renders the result of a final state
+func (runner *Runner) result(
+	state string,
+) (
+	result map[string]string,
+) {
+	stateConfig := runner.config.States[state]
+	if !stateConfig.Final || len(stateConfig.Result) == 0 {
+		return
+	}
+
+	result = make(map[string]string)
+	for name, value := range stateConfig.Result {
+		result[name] = utils.RenderTemplate(value, runner.variables)
+	}
+	return
+}
+
+// counter returns the counter with the given name
+func (runner *Runner) counter(
+	name string,
+) *counter {
+	counterObject, ok := runner.counters[name]
+	if !ok {
+		counterObject = &counter{}
+		runner.counters[name] = counterObject
+	}
+	return counterObject
+}
+
+// count changes counters, the value of a counter is also a variable
+func (runner *Runner) count(
+	counterConfig CounterConfig,
+	now time.Time,
+) {
+	change := func(name string, update func(*counter)) {
+		if name == "" {
+			return
+		}
+		counterObject := runner.counter(name)
+		update(counterObject)
+		runner.variables[name] = strconv.Itoa(counterObject.value)
+		runner.captured[name] = true
+	}
+
+	change(counterConfig.Reset, func(counterObject *counter) {
+		counterObject.reset()
+	})
+	change(counterConfig.Increment, func(counterObject *counter) {
+		counterObject.add(1, now, runner.counterWindow)
+	})
+	change(counterConfig.Decrement, func(counterObject *counter) {
+		counterObject.add(-1, now, runner.counterWindow)
+	})
+}
+
+// rearm restarts the wait of every active timer and idle event that is due
+func (runner *Runner) rearm(
+	now time.Time,
+) {
+	for _, state := range runner.config.stateChain(runner.state) {
+		eventStarts := runner.eventStarts[state]
+		for index, eventConfigObject := range runner.config.States[state].Events {
+			var interval time.Duration
+			switch eventConfig := eventConfigObject.(type) {
+			case TimerEventConfig:
+				interval = eventConfig.Interval
+			case IdleEventConfig:
+				interval = eventConfig.Interval
+			default:
+				continue
+			}
+
+			if !eventStarts[index].Add(interval).After(now) {
+				eventStarts[index] = now
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"time"
+)
+
+/*
+TimedLine is a line of output with the time it was printed
+*/
+type TimedLine struct {
+	Line string
+	Time time.Time
+}
+
+/*
+Simulate runs a config against lines with a virtual clock that follows the
+times of the lines, so timers fire as if the lines were printed at those
+times. After the last line the clock keeps running until end. Returns every
+state change.
+*/
+func Simulate(
+	config *Config,
+	start time.Time,
+	lines []TimedLine,
+	end time.Time,
+) (
+	stateChanges []StateChange,
+) {
+	runner := NewRunner(config, start)
+	if stateChange, ok := runner.Start(start); ok {
+		stateChanges = append(stateChanges, stateChange)
+	}
+
+	// advance fires all timers up to now
+	advance := func(now time.Time) {
+		for !runner.Done() {
+			deadline, ok := runner.Deadline()
+			if !ok || deadline.After(now) {
+				return
+			}
+
+			stateChange, changed := runner.HandleTime(deadline)
+			if changed {
+				stateChanges = append(stateChanges, stateChange)
+				continue
+			}
+
+			// a timer that restarts itself without interval never ends
+			if nextDeadline, _ := runner.Deadline(); !nextDeadline.After(deadline) {
+				return
+			}
+		}
+	}
+
+	for _, line := range lines {
+		advance(line.Time)
+		if runner.Done() {
+			return
+		}
+
+		stateChange, changed := runner.HandleLine(line.Line, line.Time)
+		if changed {
+			stateChanges = append(stateChanges, stateChange)
+		}
+	}
+	advance(end)
+
+	return
+}
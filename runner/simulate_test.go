@@ -0,0 +1,248 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(test *testing.T) {
+	config := makeMatchTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"Match is LIVE", start.Add(time.Minute)},
+			{"Match ended", start.Add(time.Minute * 40)},
+			{"Match is LIVE", start.Add(time.Minute * 41)},
+		},
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 3) {
+		return
+	}
+
+	assert.Equal(test, "playing", stateChanges[0].NextState)
+	assert.Equal(test, start.Add(time.Minute), stateChanges[0].Time)
+
+	assert.Equal(test, "end", stateChanges[1].NextState)
+	assert.Equal(test, []Action{CommandAction{"say bye"}}, stateChanges[1].Actions)
+
+	// the timer fires before the next line
+	assert.Equal(test, "quit", stateChanges[2].NextState)
+	assert.Equal(test, "timer", stateChanges[2].Event)
+	assert.Equal(test, start.Add(time.Minute*40+time.Second*10), stateChanges[2].Time)
+	assert.Equal(test, []Action{KillAction{}}, stateChanges[2].Actions)
+}
+
+func TestSimulateTimeout(test *testing.T) {
+	config := makeMatchTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"Server is starting", start.Add(time.Minute)},
+		},
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 1) {
+		return
+	}
+
+	assert.Equal(test, "quit", stateChanges[0].NextState)
+	assert.Equal(test, start.Add(time.Minute*15), stateChanges[0].Time)
+}
+
+func TestSimulateIdle(test *testing.T) {
+	config := makeIdleTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	// every line restarts the wait for any line
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"tick", start.Add(time.Second * 50)},
+			{"tick", start.Add(time.Second * 100)},
+		},
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 1) {
+		return
+	}
+	assert.Equal(test, "hung", stateChanges[0].NextState)
+	assert.Equal(test, "idle", stateChanges[0].Event)
+	assert.Equal(test, start.Add(time.Second*160), stateChanges[0].Time)
+	assert.Equal(test, []Action{CommandAction{"status"}}, stateChanges[0].Actions)
+
+	// lines that do not match the pattern do not count
+	var lines []TimedLine
+	for offset := time.Second * 30; offset < time.Hour; offset += time.Second * 30 {
+		lines = append(lines, TimedLine{"tick", start.Add(offset)})
+	}
+	lines[2].Line = "Player CONNECTED"
+
+	stateChanges = Simulate(
+		config,
+		start,
+		lines,
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 1) {
+		return
+	}
+	assert.Equal(test, "empty", stateChanges[0].NextState)
+	assert.Equal(test, start.Add(time.Second*90+time.Minute*5), stateChanges[0].Time)
+}
+
+func TestSimulateCounters(test *testing.T) {
+	config := makeCounterTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	lines := []TimedLine{
+		{"alice connected", start.Add(time.Second)},
+		{"bob connected", start.Add(time.Second * 2)},
+		{"bob disconnected", start.Add(time.Second * 3)},
+		{"carol connected", start.Add(time.Second * 4)},
+		{"dave connected", start.Add(time.Second * 5)},
+		{"Match ended", start.Add(time.Second * 6)},
+		{"erin connected", start.Add(time.Second * 7)},
+	}
+	// failures that are spread out never reach the threshold
+	for index := 0; index < 5; index++ {
+		lines = append(lines, TimedLine{
+			"Map change failed",
+			start.Add(time.Second*10 + time.Second*20*time.Duration(index)),
+		})
+	}
+	// but failures in a short time do
+	for index := 0; index < 5; index++ {
+		lines = append(lines, TimedLine{
+			"Map change failed",
+			start.Add(time.Minute*5 + time.Second*time.Duration(index)),
+		})
+	}
+
+	stateChanges := Simulate(
+		config,
+		start,
+		lines,
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 3) {
+		return
+	}
+
+	assert.Equal(test, "playing", stateChanges[0].NextState)
+	assert.Equal(test, "threshold", stateChanges[0].Event)
+	assert.Equal(test, start.Add(time.Second*5), stateChanges[0].Time)
+	assert.Equal(test, []Action{CommandAction{"say 3 players"}}, stateChanges[0].Actions)
+
+	assert.Equal(test, "waiting", stateChanges[1].NextState)
+
+	assert.Equal(test, "broken", stateChanges[2].NextState)
+	assert.Equal(test, start.Add(time.Minute*5+time.Second*4), stateChanges[2].Time)
+}
+
+func TestSimulateGuards(test *testing.T) {
+	config := makeGuardTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	// the captured winner is compared to the launch variable
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"blue won", start.Add(time.Second)},
+			{"start", start.Add(time.Second * 2)},
+			{"red won", start.Add(time.Second * 3)},
+		},
+		start.Add(time.Second*4),
+	)
+
+	if !assert.Len(test, stateChanges, 1) {
+		return
+	}
+	assert.Equal(test, "celebrate", stateChanges[0].NextState)
+	assert.Equal(test, []Action{CommandAction{"say red won"}}, stateChanges[0].Actions)
+
+	// a timer only fires when the condition holds
+	stateChanges = Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"3 players", start.Add(time.Minute * 5)},
+		},
+		start.Add(time.Hour),
+	)
+	assert.Empty(test, stateChanges)
+
+	// it waits another interval when the condition does not hold
+	stateChanges = Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"3 players", start.Add(time.Minute * 5)},
+			{"1 players", start.Add(time.Minute * 15)},
+		},
+		start.Add(time.Hour),
+	)
+
+	if !assert.Len(test, stateChanges, 1) {
+		return
+	}
+	// the last line restarted the timer, it changed to the same state
+	assert.Equal(test, "quit", stateChanges[0].NextState)
+	assert.Equal(test, start.Add(time.Minute*25), stateChanges[0].Time)
+}
+
+func TestSimulateNested(test *testing.T) {
+	config := makeNestedTestConfig()
+	start := time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	// the timer of the parent keeps running while the child changes
+	stateChanges := Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"Match is LIVE", start.Add(time.Minute * 10)},
+		},
+		start.Add(time.Hour*2),
+	)
+
+	if !assert.Len(test, stateChanges, 2) {
+		return
+	}
+	assert.Equal(test, "live", stateChanges[0].NextState)
+	assert.Equal(test, "quit", stateChanges[1].NextState)
+	assert.Equal(test, "timer", stateChanges[1].Event)
+	assert.Equal(test, start.Add(time.Hour), stateChanges[1].Time)
+
+	// the events of the child go before the events of the parent
+	stateChanges = Simulate(
+		config,
+		start,
+		[]TimedLine{
+			{"Match is LIVE", start.Add(time.Minute * 10)},
+			{"quit", start.Add(time.Minute * 20)},
+		},
+		start.Add(time.Hour*2),
+	)
+
+	if !assert.Len(test, stateChanges, 3) {
+		return
+	}
+	assert.Equal(test, "end", stateChanges[1].NextState)
+	assert.Equal(test, "quit", stateChanges[2].NextState)
+	assert.Equal(test, start.Add(time.Minute*20+time.Second*10), stateChanges[2].Time)
+}
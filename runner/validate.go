@@ -0,0 +1,432 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+/*
+ValidationError is a problem in a config, the path points to the problem
+*/
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+/*
+Error makes ValidationError an error
+*/
+func (err ValidationError) Error() string {
+	return err.Path + ": " + err.Message
+}
+
+/*
+Validate checks a config for problems that would make the runner behave in
+an unexpected way. A state that is not configured is only valid if it is the
+target of an event and of a transition, the runner stops in such a state
+like it does in a final state.
+*/
+func Validate(
+	config *Config,
+) (
+	errs []ValidationError,
+) {
+	validator := validator{
+		config:   config,
+		targets:  make(map[string]bool),
+		changes:  make(map[string]bool),
+		counters: make(map[string]bool),
+		parents:  make(map[string]bool),
+	}
+
+	for _, stateConfig := range config.States {
+		if stateConfig.Parent != "" {
+			validator.parents[stateConfig.Parent] = true
+		}
+		for _, eventConfig := range stateConfig.Events {
+			if nextState := eventNextState(eventConfig); nextState != "" {
+				validator.targets[nextState] = true
+			}
+			counterConfig := eventCounterConfig(eventConfig)
+			for _, name := range []string{
+				counterConfig.Increment,
+				counterConfig.Decrement,
+				counterConfig.Reset,
+			} {
+				if name != "" {
+					validator.counters[name] = true
+				}
+			}
+		}
+	}
+	for _, transitionConfig := range config.Transitions {
+		if _, to := transitionStates(transitionConfig); to != "" {
+			validator.changes[to] = true
+		}
+	}
+
+	validator.validateInitialState()
+
+	stateNames := make([]string, 0, len(config.States))
+	for state := range config.States {
+		stateNames = append(stateNames, state)
+	}
+	sort.Strings(stateNames)
+	for _, state := range stateNames {
+		validator.validateState(
+			"states."+state,
+			state,
+			config.States[state],
+		)
+	}
+
+	validator.validateTransitions(
+		"transitions",
+		config.Transitions,
+		true,
+	)
+
+	errs = validator.errs
+	return
+}
+
+// validator collects validation errors
+type validator struct {
+	config *Config
+	// targets are the next states of events
+	targets map[string]bool
+	// changes are the to states of transitions
+	changes map[string]bool
+	// counters are the counters that events change
+	counters map[string]bool
+	// parents are the states that have children
+	parents map[string]bool
+	errs    []ValidationError
+}
+
+func (validator *validator) fail(
+	path string,
+	format string,
+	args ...interface{},
+) {
+	validator.errs = append(validator.errs, ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (validator *validator) hasState(
+	state string,
+) bool {
+	_, ok := validator.config.States[state]
+	return ok
+}
+
+func (validator *validator) validateInitialState() {
+	state := validator.config.InitialState
+	if state == "" {
+		validator.fail("initialState", "missing initial state")
+		return
+	}
+	if !validator.hasState(state) {
+		validator.fail("initialState", "unknown state %q", state)
+	}
+}
+
+func (validator *validator) validateState(
+	path string,
+	state string,
+	stateConfig StateConfig,
+) {
+	validator.validateHierarchy(path, state, stateConfig)
+
+	validator.validateTransitions(
+		path+".onEnter",
+		stateConfig.OnEnter,
+		false,
+	)
+	validator.validateTransitions(
+		path+".onExit",
+		stateConfig.OnExit,
+		false,
+	)
+
+	if stateConfig.Final {
+		if validator.parents[state] {
+			validator.fail(path+".final", "composite state can not be final")
+		}
+		if len(stateConfig.Events) > 0 {
+			validator.fail(path+".events", "final state has events, they never happen")
+		}
+		return
+	}
+	if stateConfig.ExitCode != 0 {
+		validator.fail(path+".exitCode", "state is not final")
+	}
+	if len(stateConfig.Result) > 0 {
+		validator.fail(path+".result", "state is not final")
+	}
+
+	// a composite state is never the current state, a child state may use
+	// the events of its parents
+	if !validator.parents[state] && !validator.hasEvents(state) {
+		validator.fail(path, "state has no events, there is no way out")
+		return
+	}
+
+	for index, eventConfigUnknown := range stateConfig.Events {
+		eventPath := fmt.Sprintf("%s.events[%d]", path, index)
+
+		switch eventConfig := eventConfigUnknown.(type) {
+		case LiteralEventConfig:
+			if eventConfig.Value == "" {
+				validator.fail(eventPath+".value", "missing value")
+			}
+
+		case RegexEventConfig:
+
+		case TimerEventConfig:
+			if eventConfig.Interval <= 0 {
+				validator.fail(eventPath+".interval", "interval should be positive")
+			}
+
+		case ThresholdEventConfig:
+			if eventConfig.Counter == "" {
+				validator.fail(eventPath+".counter", "missing counter")
+			} else if !validator.counters[eventConfig.Counter] {
+				validator.fail(eventPath+".counter", "counter %q is never changed", eventConfig.Counter)
+			}
+			if eventConfig.Window < 0 {
+				validator.fail(eventPath+".window", "window should not be negative")
+			}
+
+		case IdleEventConfig:
+			if eventConfig.Interval <= 0 {
+				validator.fail(eventPath+".interval", "interval should be positive")
+			}
+
+		case ExitEventConfig:
+			if eventConfig.Signal != nil {
+				validator.validateSignal(
+					eventPath+".signal",
+					eventConfig.Signal,
+				)
+			}
+
+		case UnknownEventConfig:
+			validator.failType(eventPath, "event", eventConfig.Type)
+			continue
+		}
+
+		if condition := eventCondition(eventConfigUnknown); condition != nil && condition.Err() != nil {
+			validator.fail(eventPath+".when", "%v", condition.Err())
+		}
+
+		// an event that changes a counter does not need to change the state
+		nextState := eventNextState(eventConfigUnknown)
+		if nextState == "" && eventCounterConfig(eventConfigUnknown) != (CounterConfig{}) {
+			continue
+		}
+
+		validator.validateNextState(
+			eventPath+".nextState",
+			nextState,
+		)
+	}
+}
+
+func (validator *validator) validateHierarchy(
+	path string,
+	state string,
+	stateConfig StateConfig,
+) {
+	if parent := stateConfig.Parent; parent != "" {
+		if !validator.hasState(parent) {
+			validator.fail(path+".parent", "unknown state %q", parent)
+		} else if containsState(validator.config.stateChain(parent), state) {
+			validator.fail(path+".parent", "state %q is a child of %q", parent, state)
+		}
+	}
+
+	initialState := stateConfig.InitialState
+	switch {
+	case validator.parents[state] && initialState == "":
+		validator.fail(path+".initialState", "missing initial state")
+
+	case !validator.parents[state] && initialState != "":
+		validator.fail(path+".initialState", "state has no children")
+
+	case initialState != "" && validator.config.States[initialState].Parent != state:
+		validator.fail(path+".initialState", "state %q is not a child", initialState)
+	}
+}
+
+// hasEvents tells if state or one of its parents has events
+func (validator *validator) hasEvents(
+	state string,
+) bool {
+	for _, chainState := range validator.config.stateChain(state) {
+		if len(validator.config.States[chainState].Events) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (validator *validator) validateNextState(
+	path string,
+	state string,
+) {
+	if state == "" {
+		validator.fail(path, "missing next state")
+		return
+	}
+	if !validator.hasState(state) && !validator.changes[state] {
+		validator.fail(path, "unknown state %q", state)
+	}
+}
+
+func (validator *validator) validateTransitions(
+	path string,
+	transitions TransitionConfigList,
+	root bool,
+) {
+	for index, transitionConfigUnknown := range transitions {
+		transitionPath := fmt.Sprintf("%s[%d]", path, index)
+
+		if root {
+			from, to := transitionStates(transitionConfigUnknown)
+			if from != "" && !validator.hasState(from) {
+				validator.fail(transitionPath+".from", "unknown state %q", from)
+			}
+			if to != "" && !validator.hasState(to) && !validator.targets[to] {
+				validator.fail(transitionPath+".to", "unknown state %q", to)
+			}
+		}
+
+		switch transitionConfig := transitionConfigUnknown.(type) {
+		case CommandTransitionConfig:
+			if transitionConfig.Command == "" {
+				validator.fail(transitionPath+".command", "missing command")
+			}
+
+		case SignalTransitionConfig:
+			validator.validateSignal(
+				transitionPath+".signal",
+				transitionConfig.Signal,
+			)
+
+		case ShutdownTransitionConfig:
+			if transitionConfig.Command == "" && transitionConfig.Signal == nil {
+				validator.fail(transitionPath, "missing command or signal")
+			}
+			if transitionConfig.Signal != nil {
+				validator.validateSignal(
+					transitionPath+".signal",
+					transitionConfig.Signal,
+				)
+			}
+
+		case HTTPTransitionConfig:
+			if transitionConfig.URL == "" {
+				validator.fail(transitionPath+".url", "missing url")
+			}
+			if transitionConfig.Retries < 0 {
+				validator.fail(transitionPath+".retries", "retries should not be negative")
+			}
+			if transitionConfig.Timeout < 0 {
+				validator.fail(transitionPath+".timeout", "timeout should not be negative")
+			}
+
+		case ExecTransitionConfig:
+			if transitionConfig.Path == "" {
+				validator.fail(transitionPath+".path", "missing path")
+			}
+			if transitionConfig.Timeout < 0 {
+				validator.fail(transitionPath+".timeout", "timeout should not be negative")
+			}
+
+		case SequenceTransitionConfig:
+			validator.validateTransitions(
+				transitionPath+".actions",
+				transitionConfig.Actions,
+				false,
+			)
+
+		case UnknownTransitionConfig:
+			validator.failType(transitionPath, "transition", transitionConfig.Type)
+		}
+	}
+}
+
+func (validator *validator) validateSignal(
+	path string,
+	signal os.Signal,
+) {
+	if unknown, ok := signal.(UnknownSignal); ok {
+		if unknown == "" {
+			validator.fail(path, "missing signal")
+		} else {
+			validator.fail(path, "unknown signal %q", string(unknown))
+		}
+	}
+}
+
+func (validator *validator) failType(
+	path string,
+	kind string,
+	typeName string,
+) {
+	if typeName == "" {
+		validator.fail(path+".type", "missing %s type", kind)
+	} else {
+		validator.fail(path+".type", "unknown %s type %q", kind, typeName)
+	}
+}
+
+// eventNextState returns the next state of any event config
+func eventNextState(
+	eventConfigUnknown EventConfig,
+) (
+	nextState string,
+) {
+	switch eventConfig := eventConfigUnknown.(type) {
+	case LiteralEventConfig:
+		nextState = eventConfig.NextState
+
+	case RegexEventConfig:
+		nextState = eventConfig.NextState
+
+	case TimerEventConfig:
+		nextState = eventConfig.NextState
+
+	case IdleEventConfig:
+		nextState = eventConfig.NextState
+
+	case ThresholdEventConfig:
+		nextState = eventConfig.NextState
+
+	case ExitEventConfig:
+		nextState = eventConfig.NextState
+	}
+
+	return
+}
+
+// eventCounterConfig returns the counters an event config changes
+func eventCounterConfig(
+	eventConfigUnknown EventConfig,
+) (
+	counterConfig CounterConfig,
+) {
+	switch eventConfig := eventConfigUnknown.(type) {
+	case LiteralEventConfig:
+		counterConfig = eventConfig.CounterConfig
+
+	case RegexEventConfig:
+		counterConfig = eventConfig.CounterConfig
+	}
+
+	return
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateValidConfig(test *testing.T) {
+	assert.Empty(test, Validate(makeLightTestConfig()))
+	assert.Empty(test, Validate(makeSequenceTestConfig()))
+	assert.Empty(test, Validate(makeEnterExitTestConfig()))
+	assert.Empty(test, Validate(makeNestedTestConfig()))
+	assert.Empty(test, Validate(makeFinalTestConfig()))
+	assert.Empty(test, Validate(makeHTTPTestConfig()))
+	assert.Empty(test, Validate(makeExecTestConfig()))
+	assert.Empty(test, Validate(makeExitTestConfig()))
+	assert.Empty(test, Validate(makeIdleTestConfig()))
+	assert.Empty(test, Validate(makeCounterTestConfig()))
+	assert.Empty(test, Validate(makeGuardTestConfig()))
+}
+
+func TestValidateInvalidConfig(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var config Config
+	err = json.Unmarshal([]byte(`{
+		"initialState": "idel",
+		"states": {
+			"idle": {
+				"events": [
+					{ "type": "regx", "pattern": "x", "nextState": "playing" },
+					{ "type": "literal", "value": "go", "nextState": "playng" },
+					{ "type": "timer", "nextState": "end" },
+					{ "type": "exit", "signal": "SIGNOPE", "nextState": "crashed" },
+					{ "type": "threshold", "counter": "players", "value": 2, "nextState": "playing" },
+					{ "type": "literal", "value": "go", "nextState": "playing", "when": "players >=" }
+				]
+			},
+			"playing": {
+				"events": [],
+				"onEnter": [
+					{ "type": "comand", "command": "echo playing" }
+				]
+			},
+			"round": {
+				"parent": "lobby",
+				"initialState": "idle",
+				"events": [
+					{ "type": "literal", "value": "abort", "nextState": "idle" }
+				]
+			},
+			"warmup": {
+				"parent": "round",
+				"events": [],
+				"exitCode": 2
+			},
+			"crashed": {
+				"final": true,
+				"exitCode": 1
+			},
+			"won": {
+				"final": true,
+				"events": [
+					{ "type": "literal", "value": "again", "nextState": "idle" }
+				]
+			}
+		},
+		"transitions": [
+			{ "type": "signal", "from": "idle", "to": "quit", "signal": "SIGNOPE" },
+			{ "to": "playing", "command": "echo playing" },
+			{
+				"type": "sequence",
+				"from": "ending",
+				"actions": [
+					{ "type": "signal", "signal": "SIGTERM" },
+					{ "type": "signal" }
+				]
+			},
+			{ "type": "http", "to": "playing", "retries": -1 },
+			{ "type": "exec", "to": "playing", "args": ["x"] },
+			{ "type": "shutdown", "to": "playing", "timeout": 1000 }
+		]
+	}`), &config)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, []ValidationError{
+		{"initialState", `unknown state "idel"`},
+		{"states.idle.events[0].type", `unknown event type "regx"`},
+		{"states.idle.events[1].nextState", `unknown state "playng"`},
+		{"states.idle.events[2].interval", `interval should be positive`},
+		{"states.idle.events[2].nextState", `unknown state "end"`},
+		{"states.idle.events[3].signal", `unknown signal "SIGNOPE"`},
+		{"states.idle.events[4].counter", `counter "players" is never changed`},
+		{"states.idle.events[5].when", `invalid condition "players >=": unexpected end`},
+		{"states.playing.onEnter[0].type", `unknown transition type "comand"`},
+		{"states.playing", `state has no events, there is no way out`},
+		{"states.round.parent", `unknown state "lobby"`},
+		{"states.round.initialState", `state "idle" is not a child`},
+		{"states.warmup.exitCode", `state is not final`},
+		{"states.won.events", `final state has events, they never happen`},
+		{"transitions[0].to", `unknown state "quit"`},
+		{"transitions[0].signal", `unknown signal "SIGNOPE"`},
+		{"transitions[1].type", `missing transition type`},
+		{"transitions[2].from", `unknown state "ending"`},
+		{"transitions[2].actions[1].signal", `missing signal`},
+		{"transitions[3].url", `missing url`},
+		{"transitions[3].retries", `retries should not be negative`},
+		{"transitions[4].path", `missing path`},
+		{"transitions[5]", `missing command or signal`},
+	}, Validate(&config))
+}
@@ -0,0 +1,267 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+// apiLineCount is the number of output lines the api remembers
+const apiLineCount = 100
+
+/*
+StatusResponse is the response of the status endpoint of the api. Durations
+are in milliseconds.
+*/
+type StatusResponse struct {
+	State         string   `json:"state"`
+	StateDuration int64    `json:"stateDuration"`
+	Pid           int      `json:"pid"`
+	Uptime        int64    `json:"uptime"`
+	Lines         []string `json:"lines"`
+}
+
+// apiServer serves the status and control api of a running shell
+type apiServer struct {
+	mutex     sync.Mutex
+	state     string
+	stateTime time.Time
+	startTime time.Time
+	pid       int
+	lines     []string
+
+	server      *http.Server
+	done        chan struct{}
+	injectLines chan<- string
+	inputLines  chan<- string
+	signals     chan<- os.Signal
+}
+
+// startAPI starts listening for api requests on addr
+func startAPI(
+	addr string,
+	config *runner.Config,
+	inputLines chan<- string,
+	signals chan<- os.Signal,
+	injectLines chan<- string,
+) (
+	api *apiServer,
+	err error,
+) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+
+	api = &apiServer{
+		state:       config.LeafState(config.InitialState),
+		stateTime:   time.Now(),
+		done:        make(chan struct{}),
+		injectLines: injectLines,
+		inputLines:  inputLines,
+		signals:     signals,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", api.handleStatus)
+	mux.HandleFunc("/line", api.handleLine)
+	mux.HandleFunc("/command", api.handleCommand)
+	mux.HandleFunc("/signal", api.handleSignal)
+	api.server = &http.Server{Handler: mux}
+
+	go func() {
+		/*
+			ignore error, serve always returns an error when the server
+			is closed.
+		*/
+		_ = api.server.Serve(listener)
+	}()
+
+	return
+}
+
+// close stops the api and waits for pending requests
+func (api *apiServer) close() {
+	close(api.done)
+	_ = api.server.Shutdown(context.Background())
+}
+
+// started records the start of the process
+func (api *apiServer) started(
+	process *os.Process,
+) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	api.pid = process.Pid
+	api.startTime = time.Now()
+}
+
+// changed records a state change
+func (api *apiServer) changed(
+	stateChange runner.StateChange,
+) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	api.state = stateChange.NextState
+	api.stateTime = stateChange.Time
+}
+
+// watchLines remembers lines that pass through
+func (api *apiServer) watchLines(
+	lines <-chan string,
+) <-chan string {
+	watchedLines := make(chan string)
+
+	go func() {
+		defer close(watchedLines)
+
+		for line := range lines {
+			api.mutex.Lock()
+			api.lines = append(api.lines, line)
+			if len(api.lines) > apiLineCount {
+				api.lines = api.lines[len(api.lines)-apiLineCount:]
+			}
+			api.mutex.Unlock()
+
+			watchedLines <- line
+		}
+	}()
+
+	return watchedLines
+}
+
+func (api *apiServer) handleStatus(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := apiLineCount
+	if value := request.URL.Query().Get("lines"); value != "" {
+		var err error
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 0 {
+			http.Error(writer, "invalid lines", http.StatusBadRequest)
+			return
+		}
+	}
+
+	api.mutex.Lock()
+	now := time.Now()
+	if count > len(api.lines) {
+		count = len(api.lines)
+	}
+	response := StatusResponse{
+		State:         api.state,
+		StateDuration: int64(now.Sub(api.stateTime) / time.Millisecond),
+		Pid:           api.pid,
+		Lines:         append([]string{}, api.lines[len(api.lines)-count:]...),
+	}
+	if !api.startTime.IsZero() {
+		response.Uptime = int64(now.Sub(api.startTime) / time.Millisecond)
+	}
+	api.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(response)
+}
+
+func (api *apiServer) handleLine(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	line, ok := readAPIBody(writer, request)
+	if !ok {
+		return
+	}
+
+	select {
+	case api.injectLines <- line:
+		writer.WriteHeader(http.StatusNoContent)
+	case <-api.done:
+		http.Error(writer, "shutting down", http.StatusServiceUnavailable)
+	}
+}
+
+func (api *apiServer) handleCommand(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	command, ok := readAPIBody(writer, request)
+	if !ok {
+		return
+	}
+
+	select {
+	case api.inputLines <- command:
+		writer.WriteHeader(http.StatusNoContent)
+	case <-api.done:
+		http.Error(writer, "shutting down", http.StatusServiceUnavailable)
+	}
+}
+
+func (api *apiServer) handleSignal(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	name, ok := readAPIBody(writer, request)
+	if !ok {
+		return
+	}
+
+	signal := runner.ParseSignal(name)
+	if signal == nil {
+		http.Error(writer, "unknown signal", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case api.signals <- signal:
+		writer.WriteHeader(http.StatusNoContent)
+	case <-api.done:
+		http.Error(writer, "shutting down", http.StatusServiceUnavailable)
+	}
+}
+
+// readAPIBody reads the trimmed body of a POST request
+func readAPIBody(
+	writer http.ResponseWriter,
+	request *http.Request,
+) (
+	body string,
+	ok bool,
+) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = strings.TrimSpace(string(data))
+	if body == "" {
+		http.Error(writer, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	ok = true
+	return
+}
@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	inputLines := make(chan string, 1)
+	signals := make(chan os.Signal, 1)
+	injectLines := make(chan string)
+
+	api, err := startAPI(
+		"127.0.0.1:0",
+		&runner.Config{InitialState: "idle"},
+		inputLines,
+		signals,
+		injectLines,
+	)
+	if err != nil {
+		return
+	}
+	defer api.close()
+
+	outputLines := make(chan string)
+	lines := api.watchLines(outputLines)
+
+	outputLines <- "hello"
+	assert.Equal(test, "hello", <-lines)
+	outputLines <- "world"
+	assert.Equal(test, "world", <-lines)
+	close(outputLines)
+
+	api.changed(runner.StateChange{NextState: "playing"})
+
+	var recorder *httptest.ResponseRecorder
+
+	recorder = httptest.NewRecorder()
+	api.handleStatus(recorder, httptest.NewRequest("GET", "/status?lines=1", nil))
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	var status StatusResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &status)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "playing", status.State)
+	assert.Equal(test, []string{"world"}, status.Lines)
+
+	go func() {
+		recorder := httptest.NewRecorder()
+		api.handleLine(recorder, httptest.NewRequest("POST", "/line", strings.NewReader("injected\n")))
+	}()
+	assert.Equal(test, "injected", <-injectLines)
+
+	recorder = httptest.NewRecorder()
+	api.handleCommand(recorder, httptest.NewRequest("POST", "/command", strings.NewReader("say hi")))
+	assert.Equal(test, http.StatusNoContent, recorder.Code)
+	assert.Equal(test, "say hi", <-inputLines)
+
+	recorder = httptest.NewRecorder()
+	api.handleSignal(recorder, httptest.NewRequest("POST", "/signal", strings.NewReader("SIGTERM")))
+	assert.Equal(test, http.StatusNoContent, recorder.Code)
+	assert.Equal(test, syscall.SIGTERM, <-signals)
+
+	recorder = httptest.NewRecorder()
+	api.handleSignal(recorder, httptest.NewRequest("POST", "/signal", strings.NewReader("SIGNOPE")))
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	api.handleCommand(recorder, httptest.NewRequest("GET", "/command", nil))
+	assert.Equal(test, http.StatusMethodNotAllowed, recorder.Code)
+}
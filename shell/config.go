@@ -1,6 +1,9 @@
 package shell
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/Gameye/igniter-shell-go/runner"
 )
 
@@ -11,7 +14,14 @@ type Config struct {
 	Defaults map[string]string `json:"defaults"`
 	Cmd      []string          `json:"cmd"`
 	Env      map[string]string `json:"env"`
+	PassEnv  []string          `json:"passEnv"`
+	UnsetEnv []string          `json:"unsetEnv"`
 	Files    []FileConfig      `json:"files"`
+	Signals  SignalConfigMap   `json:"signals"`
+	RCON     *RCONConfig       `json:"rcon"`
+	Sources  SourceConfigList  `json:"sources"`
+	Restart  *RestartConfig    `json:"restart"`
+	Result   *ResultConfig     `json:"result"`
 	Script   *runner.Config    `json:"script"`
 }
 
@@ -22,3 +32,226 @@ type FileConfig struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
 }
+
+/*
+ResultConfig configures the match result file, it is written to Path when
+the script reaches a final state and when the process exits
+*/
+type ResultConfig struct {
+	Path string `json:"path"`
+}
+
+/*
+SignalConfigMap maps signal names to SignalConfig
+*/
+type SignalConfigMap map[string]SignalConfig
+
+/*
+SignalConfig configures what happens with a signal the shell receives. The
+action is one of forward, translate, event or ignore.
+*/
+type SignalConfig struct {
+	Action string `json:"action"`
+	Signal string `json:"signal"`
+	Line   string `json:"line"`
+}
+
+/*
+RCONConfig configures sending commands over source rcon. If Responses is
+true, the responses of the server are handled like output lines. Timeout is
+how long we keep trying to connect before a command is dropped.
+*/
+type RCONConfig struct {
+	Address   string
+	Password  string
+	Responses bool
+	Timeout   time.Duration
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *RCONConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		Address   string  `json:"address"`
+		Password  string  `json:"password"`
+		Responses bool    `json:"responses"`
+		Timeout   float64 `json:"timeout"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = RCONConfig{
+		Address:   source.Address,
+		Password:  source.Password,
+		Responses: source.Responses,
+		Timeout:   time.Duration(float64(time.Millisecond) * source.Timeout),
+	}
+
+	return
+}
+
+/*
+RestartConfig configures restarting the process when it exits while the
+script did not stop. The process is restarted at most MaxRestarts times
+within Window, or in total if there is no window. The delay before a restart
+starts at Backoff and doubles with every restart within the window, up to
+MaxBackoff. After a restart the script starts in ResumeState, or in the
+initial state if there is no resume state.
+*/
+type RestartConfig struct {
+	MaxRestarts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Window      time.Duration
+	ResumeState string
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (target *RestartConfig) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var source struct {
+		MaxRestarts int     `json:"maxRestarts"`
+		Backoff     float64 `json:"backoff"`
+		MaxBackoff  float64 `json:"maxBackoff"`
+		Window      float64 `json:"window"`
+		ResumeState string  `json:"resumeState"`
+	}
+
+	err = json.Unmarshal(data, &source)
+	if err != nil {
+		return
+	}
+
+	*target = RestartConfig{
+		MaxRestarts: source.MaxRestarts,
+		Backoff:     time.Duration(float64(time.Millisecond) * source.Backoff),
+		MaxBackoff:  time.Duration(float64(time.Millisecond) * source.MaxBackoff),
+		Window:      time.Duration(float64(time.Millisecond) * source.Window),
+		ResumeState: source.ResumeState,
+	}
+
+	return
+}
+
+/*
+SourceConfigList list of SourceConfig
+*/
+type SourceConfigList []SourceConfig
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (config *SourceConfigList) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var list []sourceConfigJSON
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return
+	}
+
+	for _, item := range list {
+		*config = append(*config, item.Payload)
+	}
+
+	return
+}
+
+/*
+SourceConfig is a source of lines for the state machine, next to the output
+of the process
+*/
+type SourceConfig interface{}
+
+/*
+FileSourceConfig tails the files that match Path, a glob pattern. Files that
+already exist when the shell starts are read from their end, files that
+appear later are read from the start.
+*/
+type FileSourceConfig struct {
+	Path string `json:"path"`
+}
+
+/*
+UDPSourceConfig receives source engine log packets on Address, the game
+server sends them after logaddress_add. If Secret is set, only packets with
+that sv_logsecret are accepted.
+*/
+type UDPSourceConfig struct {
+	Address string `json:"address"`
+	Secret  string `json:"secret"`
+}
+
+/*
+UnknownSourceConfig is a source with a type that is not known
+*/
+type UnknownSourceConfig struct {
+	Type string
+}
+
+/*
+sourceConfigJSON helper
+*/
+type sourceConfigJSON struct {
+	Payload SourceConfig
+}
+
+/*
+UnmarshalJSON provides custom unmarshalling
+*/
+func (config *sourceConfigJSON) UnmarshalJSON(
+	data []byte,
+) (
+	err error,
+) {
+	var item struct {
+		Type string `json:"type"`
+	}
+
+	err = json.Unmarshal(data, &item)
+	if err != nil {
+		return
+	}
+
+	switch item.Type {
+
+	case "file":
+		var payload FileSourceConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	case "udp":
+		var payload UDPSourceConfig
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return
+		}
+		config.Payload = payload
+
+	default:
+		config.Payload = UnknownSourceConfig{
+			Type: item.Type,
+		}
+
+	}
+
+	return
+}
@@ -0,0 +1,67 @@
+package shell
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+/*
+MakeEnviron makes the environment for the process from the inherited
+environment and the config. Only inherited variables that match one of the
+PassEnv patterns are kept (all if there are no patterns), then the UnsetEnv
+variables are removed and finally the Env variables are set, so the config
+always wins over the inherited environment.
+*/
+func MakeEnviron(
+	inherited []string,
+	config *Config,
+) (
+	environ []string,
+) {
+	for _, item := range inherited {
+		pair := strings.SplitN(item, "=", 2)
+		if !matchEnv(pair[0], config.PassEnv, true) {
+			continue
+		}
+		if matchEnv(pair[0], config.UnsetEnv, false) {
+			continue
+		}
+		if _, ok := config.Env[pair[0]]; ok {
+			continue
+		}
+		environ = append(environ, item)
+	}
+
+	keys := make([]string, 0, len(config.Env))
+	for key := range config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		environ = append(environ, key+"="+config.Env[key])
+	}
+
+	return
+}
+
+/*
+matchEnv checks if the name of a variable matches one of the patterns,
+returns empty if there are no patterns
+*/
+func matchEnv(
+	name string,
+	patterns []string,
+	empty bool,
+) bool {
+	if len(patterns) == 0 {
+		return empty
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
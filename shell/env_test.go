@@ -0,0 +1,43 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeEnviron(test *testing.T) {
+	inherited := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/steam",
+		"STEAM_TOKEN=secret",
+		"STEAM_USER=steam",
+		"DEM_FILENAME=inherited",
+	}
+
+	assert.Equal(test, []string{
+		"PATH=/usr/bin",
+		"HOME=/home/steam",
+		"STEAM_TOKEN=secret",
+		"STEAM_USER=steam",
+		"DEM_FILENAME=match.dem",
+	}, MakeEnviron(inherited, &Config{
+		Env: map[string]string{
+			"DEM_FILENAME": "match.dem",
+		},
+	}))
+
+	assert.Equal(test, []string{
+		"PATH=/usr/bin",
+		"STEAM_USER=steam",
+		"DEM_FILENAME=match.dem",
+		"LANG=C",
+	}, MakeEnviron(inherited, &Config{
+		Env: map[string]string{
+			"LANG":         "C",
+			"DEM_FILENAME": "match.dem",
+		},
+		PassEnv:  []string{"PATH", "STEAM_*"},
+		UnsetEnv: []string{"STEAM_TOKEN"},
+	}))
+}
@@ -0,0 +1,169 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+// eventQueueSize is how many events wait for a slow sink before we drop them
+const eventQueueSize = 100
+
+/*
+eventFlushTimeout is how long we wait at most for queued events to be written
+when the shell stops
+*/
+const eventFlushTimeout = time.Second
+
+/*
+StateChangeEvent is the machine readable representation of a state change
+*/
+type StateChangeEvent struct {
+	PrevState string            `json:"prevState"`
+	NextState string            `json:"nextState"`
+	Event     string            `json:"event"`
+	Line      string            `json:"line"`
+	Time      time.Time         `json:"time"`
+	Result    map[string]string `json:"result,omitempty"`
+}
+
+/*
+OpenEventSink opens a sink for state change events. The spec can be one of
+fd:<number>, file:<path> or unix:<path>.
+*/
+func OpenEventSink(
+	spec string,
+) (
+	sink io.WriteCloser,
+	err error,
+) {
+	pair := strings.SplitN(spec, ":", 2)
+	if len(pair) != 2 || pair[1] == "" {
+		err = fmt.Errorf("invalid event sink %q", spec)
+		return
+	}
+
+	switch pair[0] {
+
+	case "fd":
+		var fd int
+		fd, err = strconv.Atoi(pair[1])
+		if err != nil {
+			return
+		}
+		sink = os.NewFile(uintptr(fd), "fd"+pair[1])
+
+	case "file":
+		sink, err = os.OpenFile(
+			pair[1],
+			os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+			0644,
+		)
+
+	case "unix":
+		sink, err = net.Dial("unix", pair[1])
+
+	default:
+		err = fmt.Errorf("unknown event sink type %q", pair[0])
+
+	}
+
+	return
+}
+
+// writeStateChange writes a state change as a json line
+func writeStateChange(
+	writer io.Writer,
+	stateChange runner.StateChange,
+) (
+	err error,
+) {
+	data, err := json.Marshal(StateChangeEvent{
+		PrevState: stateChange.PrevState,
+		NextState: stateChange.NextState,
+		Event:     stateChange.Event,
+		Line:      stateChange.Line,
+		Time:      stateChange.Time,
+		Result:    stateChange.Result,
+	})
+	if err != nil {
+		return
+	}
+
+	_, err = writer.Write(append(data, '\n'))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+eventQueue writes to a sink in its own routine, so a sink that does not keep
+up never holds up the runner. Events that do not fit in the queue are
+dropped.
+*/
+type eventQueue struct {
+	events  chan []byte
+	written chan struct{}
+}
+
+// newEventQueue creates a queue that writes to sink
+func newEventQueue(
+	sink io.Writer,
+) *eventQueue {
+	queue := &eventQueue{
+		events:  make(chan []byte, eventQueueSize),
+		written: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(queue.written)
+		for event := range queue.events {
+			/*
+				ignore error, a broken sink should never affect the
+				process we are running.
+			*/
+			_, _ = sink.Write(event)
+		}
+	}()
+
+	return queue
+}
+
+// Write queues an event, it never blocks
+func (queue *eventQueue) Write(
+	data []byte,
+) (
+	n int,
+	err error,
+) {
+	select {
+	case queue.events <- append([]byte(nil), data...):
+	default:
+		fmt.Fprintf(os.Stderr, "igniter-shell: event sink is not keeping up, dropped an event\n")
+	}
+
+	n = len(data)
+	return
+}
+
+// close waits a while for the queued events to be written
+func (queue *eventQueue) close() {
+	close(queue.events)
+
+	timer := time.NewTimer(eventFlushTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-queue.written:
+	case <-timer.C:
+	}
+}
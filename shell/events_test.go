@@ -0,0 +1,122 @@
+package shell
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileEventSink(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	dir, err := ioutil.TempDir("", "igniter-shell")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := OpenEventSink("file:" + path)
+	if err != nil {
+		return
+	}
+
+	err = writeStateChange(sink, runner.StateChange{
+		PrevState: "idle",
+		NextState: "playing",
+		Event:     "literal",
+		Line:      "Match is LIVE",
+		Time:      time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC),
+		Actions:   []runner.Action{runner.CommandAction{Command: "say go"}},
+	})
+	if err != nil {
+		return
+	}
+	sink.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(
+		test,
+		`{"prevState":"idle","nextState":"playing","event":"literal","line":"Match is LIVE","time":"2020-04-07T12:00:00Z"}`+"\n",
+		string(data),
+	)
+}
+
+func TestStateChangeResult(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var buffer bytes.Buffer
+	err = writeStateChange(&buffer, runner.StateChange{
+		PrevState: "playing",
+		NextState: "completed",
+		Event:     "regex",
+		Line:      "Team red won",
+		Time:      time.Date(2020, 4, 7, 12, 0, 0, 0, time.UTC),
+		Result:    map[string]string{"winner": "red"},
+	})
+	if err != nil {
+		return
+	}
+
+	assert.Equal(
+		test,
+		`{"prevState":"playing","nextState":"completed","event":"regex","line":"Team red won","time":"2020-04-07T12:00:00Z","result":{"winner":"red"}}`+"\n",
+		buffer.String(),
+	)
+}
+
+func TestInvalidEventSink(test *testing.T) {
+	_, err := OpenEventSink("tcp:localhost:1234")
+	assert.Error(test, err)
+
+	_, err = OpenEventSink("file")
+	assert.Error(test, err)
+}
+
+func TestEventQueue(test *testing.T) {
+	// nobody reads from the sink
+	reader, writer := io.Pipe()
+	defer reader.Close()
+
+	queue := newEventQueue(writer)
+	for index := 0; index < eventQueueSize*2; index++ {
+		n, err := queue.Write([]byte("{}\n"))
+		assert.NoError(test, err)
+		assert.Equal(test, 3, n)
+	}
+
+	started := time.Now()
+	queue.close()
+	assert.True(test, time.Since(started) < eventFlushTimeout*2)
+}
+
+func TestEventQueueFlush(test *testing.T) {
+	var buffer bytes.Buffer
+	queue := newEventQueue(&buffer)
+	queue.Write([]byte(`{"nextState":"playing"}` + "\n"))
+	queue.Write([]byte(`{"nextState":"completed"}` + "\n"))
+	queue.close()
+
+	assert.Equal(
+		test,
+		`{"nextState":"playing"}`+"\n"+`{"nextState":"completed"}`+"\n",
+		buffer.String(),
+	)
+}
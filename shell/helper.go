@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+/*
+helperWaitDelay is how long we keep reading the output of a program after it
+was killed, a program that left the process group may keep it open
+*/
+const helperWaitDelay = time.Second
+
+/*
+runHelper runs the program of an exec action and returns how it exited. If
+the action wants its lines, every line of its output is passed to lines,
+otherwise the output goes to our own output. When the timeout passes the
+process group of the program is killed, so programs it started go too.
+*/
+func runHelper(
+	action runner.ExecAction,
+	lines func(string),
+) (
+	status runner.ExitStatus,
+	err error,
+) {
+	cmd := exec.Command(action.Path, action.Args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Env = os.Environ()
+	names := make([]string, 0, len(action.Env))
+	for name := range action.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd.Env = append(cmd.Env, name+"="+action.Env[name])
+	}
+	cmd.Stderr = os.Stderr
+
+	var stdout io.ReadCloser
+	if action.Lines {
+		stdout, err = cmd.StdoutPipe()
+		if err != nil {
+			return
+		}
+	} else {
+		cmd.Stdout = os.Stdout
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	if action.Timeout > 0 {
+		timer := time.AfterFunc(action.Timeout, func() {
+			signalGroup(cmd.Process, syscall.SIGKILL)
+			if stdout != nil {
+				time.AfterFunc(helperWaitDelay, func() {
+					stdout.Close()
+				})
+			}
+		})
+		defer timer.Stop()
+	}
+
+	if stdout != nil {
+		// all output is read before waiting, waiting closes the pipe
+		for line := range readLines(stdout) {
+			lines(line)
+		}
+	}
+
+	return waitCommand(cmd)
+}
+
+/*
+helperExitLine is the line that tells the runner how the program of an exec
+action exited. The code is -1 if the program could not be started or was
+terminated by a signal.
+*/
+func helperExitLine(
+	action runner.ExecAction,
+	status runner.ExitStatus,
+	err error,
+) string {
+	code := status.Code
+	if err != nil || status.Signal != nil {
+		code = -1
+	}
+	return fmt.Sprintf("exec %s exited with code %d", action.Path, code)
+}
+
+// reportHelper reports an exec action that failed
+func reportHelper(
+	action runner.ExecAction,
+	status runner.ExitStatus,
+	err error,
+) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "igniter-shell: could not run %s: %v\n", action.Path, err)
+		return
+	}
+	if status.Code != 0 || status.Signal != nil {
+		fmt.Fprintf(os.Stderr, "igniter-shell: %s ended with %v\n", action.Path, status)
+	}
+}
@@ -0,0 +1,120 @@
+package shell
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHelper(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	var lines []string
+	status, err := runHelper(runner.ExecAction{
+		Path:  "sh",
+		Args:  []string{"-c", `echo "uploading $DEMO"; echo "$0"; exit 3`, "done"},
+		Env:   map[string]string{"DEMO": "42.dem"},
+		Lines: true,
+	}, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, runner.ExitStatus{Code: 3}, status)
+	assert.Equal(test, []string{"uploading 42.dem", "done"}, lines)
+}
+
+func TestRunHelperTimeout(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	started := time.Now()
+	status, err := runHelper(runner.ExecAction{
+		Path:    "sleep",
+		Args:    []string{"10"},
+		Timeout: time.Millisecond * 100,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, syscall.SIGKILL, status.Signal)
+	assert.True(test, time.Since(started) < time.Second*5)
+
+	_, err = runHelper(runner.ExecAction{
+		Path: "/does/not/exist",
+	}, nil)
+	assert.Error(test, err)
+	err = nil
+}
+
+func TestRunHelperTimeoutGroup(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	// the sleep is a child of the shell and keeps the output open
+	var lines []string
+	started := time.Now()
+	status, err := runHelper(runner.ExecAction{
+		Path:    "sh",
+		Args:    []string{"-c", "echo started; sleep 5; echo done"},
+		Lines:   true,
+		Timeout: time.Millisecond * 200,
+	}, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, syscall.SIGKILL, status.Signal)
+	assert.Equal(test, []string{"started"}, lines)
+	assert.True(test, time.Since(started) < time.Second*2)
+}
+
+func TestPerformExecAction(test *testing.T) {
+	actions := make(chan runner.Action, 1)
+	injectLines := make(chan string, 2)
+	exited := make(chan struct{})
+	performed := make(chan struct{})
+	go func() {
+		defer close(performed)
+		performActions(nil, actions, nil, injectLines, nil, exited, Options{})
+	}()
+
+	// the output of the program is injected
+	actions <- runner.ExecAction{
+		Path:  "sh",
+		Args:  []string{"-c", "echo upload done"},
+		Lines: true,
+	}
+	assert.Equal(test, "upload done", <-injectLines)
+	assert.Equal(test, "exec sh exited with code 0", <-injectLines)
+
+	// the exit code is injected without the output
+	actions <- runner.ExecAction{
+		Path: "sh",
+		Args: []string{"-c", "exit 3"},
+	}
+	assert.Equal(test, "exec sh exited with code 3", <-injectLines)
+
+	actions <- runner.ExecAction{
+		Path: "/does/not/exist",
+	}
+	assert.Equal(test, "exec /does/not/exist exited with code -1", <-injectLines)
+
+	close(actions)
+	<-performed
+}
@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+/*
+startReaper reaps every child process that exits, including orphans that
+are adopted by us. The wait status of the process with the given pid is sent
+on the returned channel. Call stop to stop reaping.
+*/
+func startReaper(
+	pid int,
+) (
+	statuses <-chan syscall.WaitStatus,
+	stop func(),
+) {
+	childSignals := make(chan os.Signal, 1)
+	signal.Notify(childSignals, syscall.SIGCHLD)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	statusChannel := make(chan syscall.WaitStatus, 1)
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			// a child may have exited before we started listening
+			reapChildren(pid, statusChannel)
+
+			select {
+			case <-done:
+				return
+			case <-childSignals:
+			}
+		}
+	}()
+
+	statuses = statusChannel
+	stop = func() {
+		signal.Stop(childSignals)
+		close(done)
+		<-stopped
+	}
+
+	return
+}
+
+// reapChildren reaps all children that have exited
+func reapChildren(
+	pid int,
+	statuses chan<- syscall.WaitStatus,
+) {
+	for {
+		var status syscall.WaitStatus
+		reapedPid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || reapedPid <= 0 {
+			return
+		}
+		if reapedPid == pid {
+			statuses <- status
+		}
+	}
+}
+
+/*
+waitReaped waits for the reaper to reap the command and returns the exit
+status, this replaces waitCommand in init mode
+*/
+func waitReaped(
+	cmd *exec.Cmd,
+) (
+	status runner.ExitStatus,
+	err error,
+) {
+	statuses, stop := startReaper(cmd.Process.Pid)
+	defer stop()
+
+	status = makeExitStatus(<-statuses)
+
+	// the process is reaped already, so there is nothing left to wait for
+	err = cmd.Process.Release()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// signalProcess sends a signal to the process or to its process group
+func signalProcess(
+	process *os.Process,
+	signal os.Signal,
+	group bool,
+) {
+	if systemSignal, ok := signal.(syscall.Signal); ok && group {
+		signalGroup(process, systemSignal)
+		return
+	}
+
+	/*
+		ignore error, possible errors include the process to be stopped
+		or not started yet.
+	*/
+	_ = process.Signal(signal)
+}
@@ -0,0 +1,24 @@
+package shell
+
+import (
+	"syscall"
+)
+
+// prSetChildSubreaper is PR_SET_CHILD_SUBREAPER from linux/prctl.h
+const prSetChildSubreaper = 36
+
+/*
+becomeSubreaper makes orphaned descendants get adopted by us instead of by
+pid 1, so we can reap them when we are not pid 1 ourselves
+*/
+func becomeSubreaper() (
+	err error,
+) {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		err = errno
+		return
+	}
+
+	return
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package shell
+
+/*
+becomeSubreaper is not supported on this platform, orphans are only reaped
+when we are pid 1
+*/
+func becomeSubreaper() (
+	err error,
+) {
+	return
+}
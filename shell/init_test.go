@@ -0,0 +1,66 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitReaped(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	status, err := waitReaped(cmd)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, runner.ExitStatus{Code: 3}, status)
+}
+
+func TestSignalProcessGroup(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	// the child of the shell would keep stdout open if it was not signalled
+	cmd := exec.Command("sh", "-c", "sleep 10 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	start := time.Now()
+	signalProcess(cmd.Process, syscall.SIGKILL, true)
+
+	_, err = ioutil.ReadAll(stdout)
+	if err != nil {
+		return
+	}
+	assert.True(test, time.Since(start) < time.Second*5)
+
+	status, err := waitCommand(cmd)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, runner.ExitStatus{Code: -1, Signal: syscall.SIGKILL}, status)
+}
@@ -67,3 +67,42 @@ func mergeLines(cs ...<-chan string) <-chan string {
 
 	return out
 }
+
+/*
+mergeOutputLines merges the output lines of the process with other lines.
+outputClosed is closed when the output ends, after the last output line was
+taken from the merged channel, so the reader has every line of the process
+before it learns that the output ended.
+*/
+func mergeOutputLines(
+	outputLines <-chan string,
+	otherLines <-chan string,
+	outputClosed chan<- struct{},
+) <-chan string {
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+
+		for outputLines != nil || otherLines != nil {
+			select {
+			case line, more := <-outputLines:
+				if !more {
+					outputLines = nil
+					close(outputClosed)
+					continue
+				}
+				out <- line
+
+			case line, more := <-otherLines:
+				if !more {
+					otherLines = nil
+					continue
+				}
+				out <- line
+			}
+		}
+	}()
+
+	return out
+}
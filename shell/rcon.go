@@ -0,0 +1,330 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// packet types of the source rcon protocol
+const (
+	rconResponseValue = 0
+	rconExecCommand   = 2
+	rconAuthResponse  = 2
+	rconAuth          = 3
+)
+
+// rconMaxPacketSize is the largest packet a server may send
+const rconMaxPacketSize = 4096 + 10
+
+// defaultRCONTimeout is used when the rcon config has no timeout
+const defaultRCONTimeout = time.Minute
+
+// rconRetryInterval is the time between connection attempts
+const rconRetryInterval = time.Second
+
+// errRCONAuth is returned when the server does not accept the password
+var errRCONAuth = errors.New("rcon authentication failed")
+
+/*
+rconTransport sends commands over source rcon. The connection is made when
+the first command is sent, as the server might not accept connections right
+after it is started. A command that can not be sent before the timeout is
+reported and dropped, an unreachable rcon port should not stop the shell.
+*/
+type rconTransport struct {
+	config    RCONConfig
+	responses chan<- string
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	nextID  int32
+	done    chan struct{}
+	readers sync.WaitGroup
+}
+
+// newRCONTransport creates an rcon transport, responses may be nil
+func newRCONTransport(
+	config RCONConfig,
+	responses chan<- string,
+) *rconTransport {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultRCONTimeout
+	}
+
+	return &rconTransport{
+		config:    config,
+		responses: responses,
+		done:      make(chan struct{}),
+	}
+}
+
+func (transport *rconTransport) sendCommand(
+	command string,
+) (
+	err error,
+) {
+	// every line is a command of its own
+	for _, line := range strings.Split(command, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		lineErr := transport.sendLine(line)
+		if lineErr != nil {
+			fmt.Fprintf(os.Stderr, "igniter-shell: could not send %q over rcon: %v\n", line, lineErr)
+		}
+	}
+
+	return
+}
+
+// sendLine sends a single command, (re)connecting if needed
+func (transport *rconTransport) sendLine(
+	line string,
+) (
+	err error,
+) {
+	deadline := time.Now().Add(transport.config.Timeout)
+	for {
+		var conn net.Conn
+		conn, err = transport.connection()
+		if err == nil {
+			err = writeRCONPacket(
+				conn,
+				transport.newID(),
+				rconExecCommand,
+				line,
+			)
+			if err == nil {
+				return
+			}
+			transport.disconnect(conn)
+		}
+		if err == errRCONAuth || time.Now().After(deadline) {
+			return
+		}
+
+		select {
+		case <-transport.done:
+			return
+		case <-time.After(rconRetryInterval):
+		}
+	}
+}
+
+// connection returns the current connection or makes a new one
+func (transport *rconTransport) connection() (
+	conn net.Conn,
+	err error,
+) {
+	transport.mutex.Lock()
+	conn = transport.conn
+	transport.mutex.Unlock()
+	if conn != nil {
+		return
+	}
+
+	conn, err = transport.connect()
+	if err != nil {
+		return
+	}
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	select {
+	case <-transport.done:
+		conn.Close()
+		conn = nil
+		err = errors.New("rcon transport closed")
+		return
+	default:
+	}
+
+	transport.conn = conn
+	transport.readers.Add(1)
+	go transport.readResponses(conn)
+
+	return
+}
+
+// connect connects and authenticates
+func (transport *rconTransport) connect() (
+	conn net.Conn,
+	err error,
+) {
+	conn, err = net.DialTimeout("tcp", transport.config.Address, rconRetryInterval)
+	if err != nil {
+		return
+	}
+
+	authID := transport.newID()
+	err = writeRCONPacket(conn, authID, rconAuth, transport.config.Password)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	// the server may send an empty response value before the auth response
+	err = conn.SetReadDeadline(time.Now().Add(transport.config.Timeout))
+	if err != nil {
+		conn.Close()
+		return
+	}
+	for {
+		var id, packetType int32
+		id, packetType, _, err = readRCONPacket(conn)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if packetType != rconAuthResponse {
+			continue
+		}
+		if id != authID {
+			conn.Close()
+			err = errRCONAuth
+			return
+		}
+		break
+	}
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	return
+}
+
+// disconnect closes the connection if it is the current one
+func (transport *rconTransport) disconnect(
+	conn net.Conn,
+) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	conn.Close()
+	if transport.conn == conn {
+		transport.conn = nil
+	}
+}
+
+// readResponses passes responses on as lines until the connection closes
+func (transport *rconTransport) readResponses(
+	conn net.Conn,
+) {
+	defer transport.readers.Done()
+
+	for {
+		_, packetType, body, err := readRCONPacket(conn)
+		if err != nil {
+			return
+		}
+		if packetType != rconResponseValue || transport.responses == nil {
+			continue
+		}
+
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			select {
+			case transport.responses <- line:
+			case <-transport.done:
+				return
+			}
+		}
+	}
+}
+
+func (transport *rconTransport) newID() int32 {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	transport.nextID++
+	return transport.nextID
+}
+
+func (transport *rconTransport) close() (
+	err error,
+) {
+	close(transport.done)
+
+	transport.mutex.Lock()
+	if transport.conn != nil {
+		transport.conn.Close()
+		transport.conn = nil
+	}
+	transport.mutex.Unlock()
+
+	transport.readers.Wait()
+	return
+}
+
+// writeRCONPacket writes a single rcon packet
+func writeRCONPacket(
+	writer io.Writer,
+	id int32,
+	packetType int32,
+	body string,
+) (
+	err error,
+) {
+	var buffer bytes.Buffer
+	size := int32(4 + 4 + len(body) + 2)
+	_ = binary.Write(&buffer, binary.LittleEndian, size)
+	_ = binary.Write(&buffer, binary.LittleEndian, id)
+	_ = binary.Write(&buffer, binary.LittleEndian, packetType)
+	buffer.WriteString(body)
+	buffer.Write([]byte{0, 0})
+
+	_, err = writer.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// readRCONPacket reads a single rcon packet
+func readRCONPacket(
+	reader io.Reader,
+) (
+	id int32,
+	packetType int32,
+	body string,
+	err error,
+) {
+	var size int32
+	err = binary.Read(reader, binary.LittleEndian, &size)
+	if err != nil {
+		return
+	}
+	if size < 10 || size > rconMaxPacketSize {
+		err = fmt.Errorf("invalid rcon packet size %d", size)
+		return
+	}
+
+	data := make([]byte, size)
+	_, err = io.ReadFull(reader, data)
+	if err != nil {
+		return
+	}
+
+	id = int32(binary.LittleEndian.Uint32(data[0:4]))
+	packetType = int32(binary.LittleEndian.Uint32(data[4:8]))
+	body = strings.TrimRight(string(data[8:]), "\x00")
+
+	return
+}
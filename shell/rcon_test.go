@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRCONTransport(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	commands := make(chan string, 10)
+	address, stop, err := startFakeRCONServer("secret", commands)
+	if err != nil {
+		return
+	}
+	defer stop()
+
+	responses := make(chan string, 10)
+	transport := newRCONTransport(RCONConfig{
+		Address:  address,
+		Password: "secret",
+		Timeout:  time.Second * 5,
+	}, responses)
+	defer transport.close()
+
+	err = transport.sendCommand("echo hello\n\nsay world\n")
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, "echo hello", <-commands)
+	assert.Equal(test, "say world", <-commands)
+	assert.Equal(test, "> echo hello", <-responses)
+	assert.Equal(test, "> say world", <-responses)
+}
+
+func TestRCONTransportAuth(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	commands := make(chan string, 10)
+	address, stop, err := startFakeRCONServer("secret", commands)
+	if err != nil {
+		return
+	}
+	defer stop()
+
+	transport := newRCONTransport(RCONConfig{
+		Address:  address,
+		Password: "wrong",
+		Timeout:  time.Second * 5,
+	}, nil)
+	defer transport.close()
+
+	assert.Equal(test, errRCONAuth, transport.sendLine("echo hello"))
+	assert.Empty(test, commands)
+}
+
+/*
+startFakeRCONServer starts a server that speaks just enough source rcon to
+test with. Commands are passed on and echoed as the response.
+*/
+func startFakeRCONServer(
+	password string,
+	commands chan<- string,
+) (
+	address string,
+	stop func(),
+	err error,
+) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRCON(conn, password, commands)
+		}
+	}()
+
+	address = listener.Addr().String()
+	stop = func() {
+		listener.Close()
+	}
+
+	return
+}
+
+func serveFakeRCON(
+	conn net.Conn,
+	password string,
+	commands chan<- string,
+) {
+	defer conn.Close()
+
+	for {
+		id, packetType, body, err := readRCONPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch packetType {
+		case rconAuth:
+			_ = writeRCONPacket(conn, id, rconResponseValue, "")
+			if body != password {
+				id = -1
+			}
+			_ = writeRCONPacket(conn, id, rconAuthResponse, "")
+
+		case rconExecCommand:
+			commands <- body
+			_ = writeRCONPacket(conn, id, rconResponseValue, "> "+body+"\n")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+/*
+restartPolicy decides if and when a process that exited unexpectedly is
+restarted
+*/
+type restartPolicy struct {
+	config RestartConfig
+	// restarts are the times of the restarts within the window
+	restarts []time.Time
+}
+
+func newRestartPolicy(
+	config RestartConfig,
+) *restartPolicy {
+	return &restartPolicy{
+		config: config,
+	}
+}
+
+/*
+next returns how long to wait before the next restart, ok is false if the
+process restarted too often and should not be restarted anymore. Every
+restart within the window doubles the delay.
+*/
+func (policy *restartPolicy) next(
+	now time.Time,
+) (
+	delay time.Duration,
+	ok bool,
+) {
+	if policy.config.Window > 0 {
+		recent := policy.restarts[:0]
+		for _, restart := range policy.restarts {
+			if now.Sub(restart) < policy.config.Window {
+				recent = append(recent, restart)
+			}
+		}
+		policy.restarts = recent
+	}
+
+	if policy.config.MaxRestarts > 0 &&
+		len(policy.restarts) >= policy.config.MaxRestarts {
+		return
+	}
+
+	delay = policy.config.Backoff
+	for range policy.restarts {
+		if policy.config.MaxBackoff > 0 && delay >= policy.config.MaxBackoff ||
+			delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if policy.config.MaxBackoff > 0 && delay > policy.config.MaxBackoff {
+		delay = policy.config.MaxBackoff
+	}
+
+	policy.restarts = append(policy.restarts, now)
+	ok = true
+	return
+}
+
+/*
+resumeConfig returns the config for the runner of the restarted process, it
+starts in the resume state if there is one
+*/
+func (policy *restartPolicy) resumeConfig(
+	config *runner.Config,
+) *runner.Config {
+	if policy.config.ResumeState == "" {
+		return config
+	}
+
+	resumed := *config
+	resumed.InitialState = policy.config.ResumeState
+	return &resumed
+}
+
+// cloneCommand creates a command that can be started like cmd was
+func cloneCommand(
+	cmd *exec.Cmd,
+) *exec.Cmd {
+	clone := &exec.Cmd{
+		Path:       cmd.Path,
+		Args:       cmd.Args,
+		Env:        cmd.Env,
+		Dir:        cmd.Dir,
+		ExtraFiles: cmd.ExtraFiles,
+	}
+	if cmd.SysProcAttr != nil {
+		sysProcAttr := *cmd.SysProcAttr
+		clone.SysProcAttr = &sysProcAttr
+	}
+	return clone
+}
+
+// reportRestart reports a restart, or that we gave up restarting
+func reportRestart(
+	exit int,
+	delay time.Duration,
+	ok bool,
+) {
+	if !ok {
+		fmt.Fprintf(os.Stderr, "igniter-shell: process exited with %d, restarted too often\n", exit)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "igniter-shell: process exited with %d, restarting in %v\n", exit, delay)
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartPolicy(test *testing.T) {
+	policy := newRestartPolicy(RestartConfig{
+		MaxRestarts: 3,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Second * 3,
+		Window:      time.Minute,
+	})
+	start := time.Now()
+
+	for _, expected := range []time.Duration{
+		time.Second,
+		time.Second * 2,
+		time.Second * 3,
+	} {
+		delay, ok := policy.next(start)
+		assert.True(test, ok)
+		assert.Equal(test, expected, delay)
+	}
+
+	// crash loop
+	_, ok := policy.next(start.Add(time.Second * 30))
+	assert.False(test, ok)
+
+	// the restarts left the window
+	delay, ok := policy.next(start.Add(time.Minute * 2))
+	assert.True(test, ok)
+	assert.Equal(test, time.Second, delay)
+}
+
+func TestRestartResumeConfig(test *testing.T) {
+	config := &runner.Config{InitialState: "idle"}
+
+	policy := newRestartPolicy(RestartConfig{})
+	assert.Equal(test, "idle", policy.resumeConfig(config).InitialState)
+
+	policy = newRestartPolicy(RestartConfig{ResumeState: "playing"})
+	assert.Equal(test, "playing", policy.resumeConfig(config).InitialState)
+	assert.Equal(test, "idle", config.InitialState)
+}
+
+func TestRunWithRestart(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	dir, err := ioutil.TempDir("", "restart")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// fails the first time it runs
+	counter := filepath.Join(dir, "counter")
+	cmd := exec.Command("sh", "-c", `
+		echo x >> "$0"
+		[ "$(wc -l < "$0")" -ge 2 ]
+	`, counter)
+
+	config := &runner.Config{
+		InitialState: "playing",
+		States: runner.StateConfigMap{
+			"playing": runner.StateConfig{
+				Events: runner.EventConfigList{
+					runner.ExitEventConfig{
+						Code:      new(int),
+						NextState: "finished",
+					},
+				},
+			},
+			"finished": runner.StateConfig{
+				Final:    true,
+				ExitCode: 5,
+			},
+		},
+	}
+
+	exit, err := RunWithRunner(cmd, config, false, Options{
+		Restart: &RestartConfig{
+			MaxRestarts: 1,
+			Backoff:     time.Millisecond * 10,
+		},
+	})
+	if err != nil {
+		return
+	}
+	assert.Equal(test, 5, exit)
+
+	content, err := ioutil.ReadFile(counter)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "x\nx\n", string(content))
+}
@@ -0,0 +1,172 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+/*
+MatchResult is the machine readable summary of a match, it lists every state
+that was visited with the time spent in it and the captured variables as of
+the last state change
+*/
+type MatchResult struct {
+	States     []StateVisitResult `json:"states"`
+	Variables  map[string]string  `json:"variables"`
+	FinalState string             `json:"finalState"`
+	Final      bool               `json:"final"`
+	Result     map[string]string  `json:"result,omitempty"`
+}
+
+/*
+StateVisitResult is a visit of a state, the duration is in milliseconds
+*/
+type StateVisitResult struct {
+	State    string    `json:"state"`
+	Enter    time.Time `json:"enter"`
+	Exit     time.Time `json:"exit"`
+	Duration float64   `json:"duration"`
+}
+
+/*
+resultRecorder keeps the history of the runner over restarts and writes the
+match result to a file. A nil recorder records nothing.
+*/
+type resultRecorder struct {
+	config  ResultConfig
+	history *runner.History
+}
+
+// newResultRecorder creates a recorder, or nil if there is no config
+func newResultRecorder(
+	config *ResultConfig,
+) *resultRecorder {
+	if config == nil {
+		return nil
+	}
+
+	return &resultRecorder{
+		config:  *config,
+		history: runner.NewHistory(),
+	}
+}
+
+// start records the state the runner starts in
+func (recorder *resultRecorder) start(
+	state string,
+	now time.Time,
+) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.history.Enter(state, now)
+}
+
+// add records a state change and writes the result if the state is final
+func (recorder *resultRecorder) add(
+	config *runner.Config,
+	stateChange runner.StateChange,
+) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.history.Add(stateChange)
+	if config.IsFinal(stateChange.NextState) {
+		recorder.write(config, stateChange.Time)
+	}
+}
+
+/*
+write writes the result, errors are reported and ignored as a missing
+result should not change how the shell exits
+*/
+func (recorder *resultRecorder) write(
+	config *runner.Config,
+	now time.Time,
+) {
+	if recorder == nil {
+		return
+	}
+
+	err := writeMatchResult(
+		recorder.config.Path,
+		makeMatchResult(recorder.history, config, now),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "igniter-shell: could not write result: %v\n", err)
+	}
+}
+
+// makeMatchResult summarizes history, the current state ends now
+func makeMatchResult(
+	history *runner.History,
+	config *runner.Config,
+	now time.Time,
+) (
+	result MatchResult,
+) {
+	result = MatchResult{
+		States:     []StateVisitResult{},
+		Variables:  history.Variables(),
+		FinalState: history.State(),
+		Final:      config.IsFinal(history.State()),
+		Result:     history.Result(),
+	}
+
+	for _, visit := range history.Visits() {
+		exit := visit.Exit
+		if exit.IsZero() {
+			exit = now
+		}
+		result.States = append(result.States, StateVisitResult{
+			State:    visit.State,
+			Enter:    visit.Enter,
+			Exit:     exit,
+			Duration: float64(exit.Sub(visit.Enter)) / float64(time.Millisecond),
+		})
+	}
+
+	return
+}
+
+/*
+writeMatchResult writes result as json to path. The file is replaced at once,
+so a reader never sees half a result.
+*/
+func writeMatchResult(
+	path string,
+	result MatchResult,
+) (
+	err error,
+) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+
+	temporaryPath := path + ".tmp"
+	err = ioutil.WriteFile(temporaryPath, append(data, '\n'), 0644)
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		return
+	}
+
+	return
+}
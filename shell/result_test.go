@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithResult(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	dir, err := ioutil.TempDir("", "result")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command("sh", "-c", `
+		echo "Match is LIVE"
+		echo "Team red won"
+	`)
+
+	config := &runner.Config{
+		InitialState: "idle",
+		// launch variables are not part of the result
+		Variables: map[string]string{"token": "SECRET_GSLT"},
+		States: runner.StateConfigMap{
+			"idle": runner.StateConfig{
+				Events: runner.EventConfigList{
+					runner.LiteralEventConfig{
+						Value:     "Match is LIVE",
+						NextState: "playing",
+					},
+				},
+			},
+			"playing": runner.StateConfig{
+				Events: runner.EventConfigList{
+					runner.RegexEventConfig{
+						Regexp:    regexp.MustCompile(`^Team (?P<winner>\w+) won$`),
+						NextState: "completed",
+					},
+				},
+			},
+			"completed": runner.StateConfig{
+				Final:    true,
+				ExitCode: 4,
+				Result: map[string]string{
+					"winner": "${winner}",
+				},
+			},
+		},
+	}
+
+	path := filepath.Join(dir, "match", "result.json")
+	exit, err := RunWithRunner(cmd, config, false, Options{
+		Result: &ResultConfig{Path: path},
+	})
+	if err != nil {
+		return
+	}
+	assert.Equal(test, 4, exit)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var result MatchResult
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return
+	}
+
+	assert.Equal(test, "completed", result.FinalState)
+	assert.True(test, result.Final)
+	assert.Equal(test, map[string]string{"winner": "red"}, result.Variables)
+	assert.Equal(test, map[string]string{"winner": "red"}, result.Result)
+
+	states := make([]string, 0, len(result.States))
+	for _, visit := range result.States {
+		states = append(states, visit.State)
+		assert.False(test, visit.Exit.Before(visit.Enter))
+	}
+	assert.Equal(test, []string{"idle", "playing", "completed"}, states)
+}
@@ -1,69 +1,152 @@
 package shell
 
 import (
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/Gameye/igniter-shell-go/runner"
 	"github.com/kr/pty"
 )
 
-// RunWithRunner runs a command
+/*
+Options are the optional features of the shell
+*/
+type Options struct {
+	/*
+		Events receives every state change as a json line, may be nil.
+		Events are dropped when it does not keep up.
+	*/
+	Events io.Writer
+	// APIAddr is the address the status and control api listens on
+	APIAddr string
+	// Signals configures what happens with signals the shell receives
+	Signals SignalConfigMap
+	/*
+		Init makes the shell behave like an init process, it reaps zombies
+		and signals and kills the process group of the process
+	*/
+	Init bool
+	// RCON sends commands over source rcon instead of stdin, may be nil
+	RCON *RCONConfig
+	// Sources are read next to the output of the process
+	Sources SourceConfigList
+	// Restart restarts the process when it exits unexpectedly, may be nil
+	Restart *RestartConfig
+	// Result writes a summary of the match to a file, may be nil
+	Result *ResultConfig
+}
+
+/*
+exitGrace is how long we wait at most for the last output after the process
+exits. The output of a pty is never closed, as we keep the terminal open.
+*/
+const exitGrace = time.Millisecond * 500
+
+/*
+RunWithRunner runs a command. With a restart policy in the options, the
+command is started again when it exits while the runner did not stop.
+*/
 func RunWithRunner(
 	cmd *exec.Cmd,
 	config *runner.Config,
 	withPty bool,
+	options Options,
 ) (
 	exit int,
 	err error,
 ) {
+	var policy *restartPolicy
+	if options.Restart != nil {
+		policy = newRestartPolicy(*options.Restart)
+	}
+	recorder := newResultRecorder(options.Result)
+	if options.Events != nil {
+		events := newEventQueue(options.Events)
+		defer events.close()
+		options.Events = events
+	}
 
-	if withPty {
-		exit, err = runCommandPTY(cmd, config)
-		if err != nil {
+	for {
+		var stopped bool
+		if withPty {
+			exit, stopped, err = runCommandPTY(cmd, config, recorder, options)
+			if err != nil {
+				return
+			}
+		} else {
+			exit, stopped, err = runCommand(cmd, config, recorder, options)
+			if err != nil {
+				return
+			}
+		}
+
+		if stopped || policy == nil {
 			return
 		}
-	} else {
-		exit, err = runCommand(cmd, config)
-		if err != nil {
+
+		delay, ok := policy.next(time.Now())
+		reportRestart(exit, delay, ok)
+		if !ok {
 			return
 		}
-	}
+		time.Sleep(delay)
 
-	return
+		cmd = cloneCommand(cmd)
+		config = policy.resumeConfig(config)
+	}
 }
 
 // runCommand runs a command
 func runCommand(
 	cmd *exec.Cmd,
 	config *runner.Config,
+	recorder *resultRecorder,
+	options Options,
 ) (
 	exit int,
+	stopped bool,
 	err error,
 ) {
+	if options.Init {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Setpgid = true
+	}
+
 	// setup pipes
 
+	// closing the writers lets the copy routines end
 	stdoutPipeReader, stdoutPipeWriter := io.Pipe()
 	stderrPipeReader, stderrPipeWriter := io.Pipe()
-	defer stdoutPipeReader.Close()
 	defer stdoutPipeWriter.Close()
-	defer stderrPipeReader.Close()
 	defer stderrPipeWriter.Close()
 
-	stdout, err := cmd.StdoutPipe()
+	/*
+		unlike the pipes of cmd, these are not closed when the process
+		exits, so we can read the output to the end
+	*/
+	stdout, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		return
 	}
 	defer stdout.Close()
+	defer stdoutWriter.Close()
+	cmd.Stdout = stdoutWriter
 
-	stderr, err := cmd.StderrPipe()
+	stderr, stderrWriter, err := os.Pipe()
 	if err != nil {
 		return
 	}
 	defer stderr.Close()
+	defer stderrWriter.Close()
+	cmd.Stderr = stderrWriter
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -80,30 +163,8 @@ func runCommand(
 	stderrLines := readLines(stderrTee)
 	outputLines := mergeLines(stdoutLines, stderrLines)
 
-	stateChanges := runner.Run(config, outputLines)
-
-	inputLines := make(chan string)
-	defer close(inputLines)
-	signals := make(chan os.Signal, 20)
-	defer close(signals)
-
 	// start routines
 
-	go func() {
-		for stateChangeUnknown := range stateChanges {
-			switch stateChange := stateChangeUnknown.(type) {
-			case runner.CommandStateChange:
-				inputLines <- stateChange.Command
-
-			case runner.SignalStateChange:
-				signals <- stateChange.Signal
-
-			case runner.KillStateChange:
-				cmd.Process.Kill()
-			}
-		}
-	}()
-
 	go func() {
 		var err error
 		_, err = io.Copy(os.Stdout, stdoutPipeReader)
@@ -119,36 +180,26 @@ func runCommand(
 		}
 	}()
 	go func() {
-		var err error
-		_, err = io.Copy(stdin, os.Stdin)
-		if err != nil {
-			panic(err)
-		}
-	}()
-
-	go func() {
-		var err error
-		err = passLines(stdin, inputLines)
-		if err != nil {
-			panic(err)
-		}
+		// the process may exit, and be restarted, before stdin ends
+		_, _ = io.Copy(stdin, os.Stdin)
 	}()
 
-	// start the command
-
-	err = cmd.Start()
-	if err != nil {
-		return
+	// the output ends when the process, and every child, closed it
+	started := func() {
+		stdoutWriter.Close()
+		stderrWriter.Close()
 	}
 
-	signal.Notify(signals)
-	defer signal.Stop(signals)
-
-	go passSignals(cmd.Process, signals)
-
-	// wait for exit
-
-	exit, err = waitCommand(cmd)
+	exit, stopped, err = runProcess(
+		cmd,
+		config,
+		recorder,
+		outputLines,
+		stdin,
+		nil,
+		started,
+		options,
+	)
 	if err != nil {
 		return
 	}
@@ -160,12 +211,15 @@ func runCommand(
 func runCommandPTY(
 	cmd *exec.Cmd,
 	config *runner.Config,
+	recorder *resultRecorder,
+	options Options,
 ) (
 	exit int,
+	stopped bool,
 	err error,
 ) {
+	// closing the writer lets the copy routine end
 	pipeReader, pipeWriter := io.Pipe()
-	defer pipeReader.Close()
 	defer pipeWriter.Close()
 
 	ptyStream, ttyStream, err := pty.Open()
@@ -189,30 +243,9 @@ func runCommandPTY(
 	// setup pipeline
 
 	outputLines := readLines(ptyTee)
-	stateChanges := runner.Run(config, outputLines)
-
-	inputLines := make(chan string)
-	defer close(inputLines)
-	signals := make(chan os.Signal, 20)
-	defer close(signals)
 
 	// start routines
 
-	go func() {
-		for stateChangeUnknown := range stateChanges {
-			switch stateChange := stateChangeUnknown.(type) {
-			case runner.CommandStateChange:
-				inputLines <- stateChange.Command
-
-			case runner.SignalStateChange:
-				signals <- stateChange.Signal
-
-			case runner.KillStateChange:
-				cmd.Process.Kill()
-			}
-		}
-	}()
-
 	go func() {
 		var err error
 		_, err = io.Copy(os.Stdout, pipeReader)
@@ -221,16 +254,115 @@ func runCommandPTY(
 		}
 	}()
 	go func() {
-		var err error
-		_, err = io.Copy(ptyStream, os.Stdin)
+		// the process may exit, and be restarted, before stdin ends
+		_, _ = io.Copy(ptyStream, os.Stdin)
+	}()
+
+	// the size of our own terminal, if any, is passed on to the pty
+	resize := func() {
+		_ = pty.InheritSize(os.Stdin, ptyStream)
+	}
+	resize()
+
+	exit, stopped, err = runProcess(
+		cmd,
+		config,
+		recorder,
+		outputLines,
+		ptyStream,
+		resize,
+		nil,
+		options,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+runProcess starts the command and runs the runner on the output lines of the
+process until it exits. Commands are written to input, resize is called when
+our own terminal is resized and started is called after the process started,
+both may be nil.
+*/
+func runProcess(
+	cmd *exec.Cmd,
+	config *runner.Config,
+	recorder *resultRecorder,
+	outputLines <-chan string,
+	input io.Writer,
+	resize func(),
+	started func(),
+	options Options,
+) (
+	exit int,
+	stopped bool,
+	err error,
+) {
+	policy, err := makeSignalPolicy(options.Signals, resize)
+	if err != nil {
+		return
+	}
+
+	inputLines := make(chan string)
+	defer close(inputLines)
+	signals := make(chan os.Signal, 20)
+	defer close(signals)
+	injectLines := make(chan string)
+	defer close(injectLines)
+
+	var sourceLines <-chan string
+	if len(options.Sources) > 0 {
+		var stopSources func()
+		sourceLines, stopSources, err = startSources(options.Sources)
 		if err != nil {
-			panic(err)
+			return
 		}
-	}()
+		defer stopSources()
+	}
+
+	var api *apiServer
+	if options.APIAddr != "" {
+		api, err = startAPI(options.APIAddr, config, inputLines, signals, injectLines)
+		if err != nil {
+			return
+		}
+		defer api.close()
+		outputLines = api.watchLines(outputLines)
+		if sourceLines != nil {
+			sourceLines = api.watchLines(sourceLines)
+		}
+	}
+
+	var otherLines <-chan string = injectLines
+	if sourceLines != nil {
+		otherLines = mergeLines(sourceLines, injectLines)
+	}
+
+	// the output of the process is done some time after it exits
+	outputClosed := make(chan struct{})
+	outputLines = mergeOutputLines(outputLines, otherLines, outputClosed)
+
+	exitStatuses := make(chan runner.ExitStatus, 1)
+	exited := make(chan struct{})
+
+	// start routines
+
+	var transport commandTransport = writerTransport{input}
+	if options.RCON != nil {
+		var responses chan<- string
+		if options.RCON.Responses {
+			responses = injectLines
+		}
+		transport = newRCONTransport(*options.RCON, responses)
+	}
+	defer transport.close()
 
 	go func() {
 		var err error
-		err = passLines(ptyStream, inputLines)
+		err = passLines(transport, inputLines, exited)
 		if err != nil {
 			panic(err)
 		}
@@ -238,59 +370,297 @@ func runCommandPTY(
 
 	// start the command
 
+	if options.Init {
+		err = becomeSubreaper()
+		if err != nil {
+			return
+		}
+	}
+
 	err = cmd.Start()
 	if err != nil {
 		return
 	}
+	if started != nil {
+		started()
+	}
 
-	signal.Notify(signals)
-	defer signal.Stop(signals)
+	if api != nil {
+		api.started(cmd.Process)
+	}
+
+	// the runner starts with the process, actions of the initial state need it
+	recorder.start(config.LeafState(config.InitialState), time.Now())
+	stateChanges := runner.Run(config, outputLines, exitStatuses)
+
+	finalStates := make(chan string, 1)
+	go func() {
+		finalStates <- handleStateChanges(
+			cmd,
+			config,
+			recorder,
+			outputLines,
+			stateChanges,
+			inputLines,
+			injectLines,
+			signals,
+			exited,
+			api,
+			options,
+		)
+	}()
 
-	go passSignals(cmd.Process, signals)
+	incomingSignals := make(chan os.Signal, 20)
+	signal.Notify(incomingSignals)
+	stopSignals := routeSignals(
+		incomingSignals,
+		policy,
+		signals,
+		injectLines,
+	)
+	defer stopSignals()
+
+	go passSignals(cmd.Process, signals, options.Init)
 
 	// wait for exit
 
-	exit, err = waitCommand(cmd)
+	var status runner.ExitStatus
+	if options.Init {
+		status, err = waitReaped(cmd)
+	} else {
+		status, err = waitCommand(cmd)
+	}
+	close(exited)
 	if err != nil {
 		return
 	}
+	exit = status.Code
+
+	// let the runner handle the last output and the exit
+	select {
+	case <-outputClosed:
+	case <-time.After(exitGrace):
+	}
+	exitStatuses <- status
+
+	finalState := <-finalStates
+	if code, ok := config.ExitCode(finalState); ok {
+		exit = code
+	}
+	stopped = config.IsFinal(finalState)
+	recorder.write(config, time.Now())
 
 	return
 }
 
-// waitCommand waits for a command to exit and returns the exit code
+/*
+handleStateChanges handles state changes until the runner stops and all
+actions are performed, it returns the state the runner stopped in. The
+output lines the runner reads are drained once it stops, so the process
+never blocks on its output.
+*/
+func handleStateChanges(
+	cmd *exec.Cmd,
+	config *runner.Config,
+	recorder *resultRecorder,
+	outputLines <-chan string,
+	stateChanges <-chan runner.StateChange,
+	inputLines chan<- string,
+	injectLines chan<- string,
+	signals chan<- os.Signal,
+	exited <-chan struct{},
+	api *apiServer,
+	options Options,
+) string {
+	/*
+		actions are performed in their own routine so waiting does not
+		block the runner
+	*/
+	actions := make(chan runner.Action, 100)
+	performed := make(chan struct{})
+	defer func() {
+		close(actions)
+		<-performed
+	}()
+
+	go func() {
+		defer close(performed)
+		performActions(
+			cmd,
+			actions,
+			inputLines,
+			injectLines,
+			signals,
+			exited,
+			options,
+		)
+	}()
+
+	state := config.LeafState(config.InitialState)
+	for stateChange := range stateChanges {
+		state = stateChange.NextState
+		recorder.add(config, stateChange)
+
+		if api != nil {
+			api.changed(stateChange)
+		}
+
+		if options.Events != nil {
+			/*
+				ignore error, a broken sink should never affect the
+				process we are running.
+			*/
+			_ = writeStateChange(options.Events, stateChange)
+		}
+
+		for _, action := range stateChange.Actions {
+			actions <- action
+		}
+	}
+
+	// the runner stopped, nobody reads the lines anymore
+	go func() {
+		for range outputLines {
+		}
+	}()
+
+	return state
+}
+
+// performActions performs actions in order
+func performActions(
+	cmd *exec.Cmd,
+	actions <-chan runner.Action,
+	inputLines chan<- string,
+	injectLines chan<- string,
+	signals chan<- os.Signal,
+	exited <-chan struct{},
+	options Options,
+) {
+	// http and exec actions do not hold up other actions, but we wait for them
+	var requests sync.WaitGroup
+	defer requests.Wait()
+
+	for actionUnknown := range actions {
+		switch action := actionUnknown.(type) {
+		case runner.CommandAction:
+			select {
+			case <-exited:
+				// nobody is reading commands anymore
+			default:
+				inputLines <- action.Command
+			}
+
+		case runner.SignalAction:
+			signals <- action.Signal
+
+		case runner.KillAction:
+			// like the last stage of a shutdown
+			signalGroup(cmd.Process, syscall.SIGKILL)
+
+		case runner.WaitAction:
+			time.Sleep(action.Interval)
+
+		case runner.HTTPAction:
+			requests.Add(1)
+			go func() {
+				defer requests.Done()
+				reportHTTP(action, postHTTP(action))
+			}()
+
+		case runner.ExecAction:
+			requests.Add(1)
+			go func() {
+				defer requests.Done()
+				inject := func(line string) {
+					select {
+					case injectLines <- line:
+					case <-exited:
+						// the runner does not handle lines anymore
+					}
+				}
+				status, err := runHelper(action, inject)
+				reportHelper(action, status, err)
+				// the script can react to how the program exited
+				inject(helperExitLine(action, status, err))
+			}()
+
+		case runner.ShutdownAction:
+			reportShutdown(shutdown(
+				cmd,
+				action,
+				inputLines,
+				signals,
+				exited,
+			))
+		}
+	}
+}
+
+// waitCommand waits for a command to exit and returns the exit status
 func waitCommand(
 	cmd *exec.Cmd,
 ) (
-	exit int,
+	status runner.ExitStatus,
 	err error,
 ) {
 	err = cmd.Wait()
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-			err = nil
-			exit = status.ExitStatus()
-			return
-		}
+	if _, ok := err.(*exec.ExitError); ok {
+		err = nil
 	}
-
 	if err != nil {
 		return
 	}
 
+	if waitStatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		status = makeExitStatus(waitStatus)
+	}
+
 	return
 }
 
-// passLines writes lines from a channel in a writer
+/*
+makeExitStatus makes an exit status for the runner, the code is -1 if the
+process was terminated by a signal
+*/
+func makeExitStatus(
+	waitStatus syscall.WaitStatus,
+) (
+	status runner.ExitStatus,
+) {
+	status.Code = waitStatus.ExitStatus()
+	if waitStatus.Signaled() {
+		status.Signal = waitStatus.Signal()
+	}
+	return
+}
+
+/*
+passLines sends lines from a channel as commands with a transport. Once the
+process exited lines are dropped, waiting for the process closes its input.
+*/
 func passLines(
-	writer io.Writer,
+	transport commandTransport,
 	lines <-chan string,
+	exited <-chan struct{},
 ) (
 	err error,
 ) {
 	var line string
 	for line = range lines {
-		_, err = io.WriteString(writer, line+"\n")
+		select {
+		case <-exited:
+			// nobody is reading commands anymore
+			continue
+		default:
+		}
+
+		err = transport.sendCommand(line)
+		if errors.Is(err, os.ErrClosed) {
+			// the process exited while we were sending
+			err = nil
+			continue
+		}
 		if err != nil {
 			return
 		}
@@ -298,18 +668,18 @@ func passLines(
 	return
 }
 
-// passSignals passes singnals from a channel to a process
+/*
+passSignals passes singnals from a channel to a process, or to the process
+group of the process
+*/
 func passSignals(
 	process *os.Process,
 	signals <-chan os.Signal,
+	group bool,
 ) {
 	var signal os.Signal
 	for signal = range signals {
-		/*
-			ignore error, possible errors include the process to be stopped
-			or not started yet.
-		*/
-		_ = process.Signal(signal)
+		signalProcess(process, signal, group)
 	}
 	return
 }
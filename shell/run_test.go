@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassLinesAfterExit(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	lines := make(chan string)
+	exited := make(chan struct{})
+	passed := make(chan error)
+	go func() {
+		passed <- passLines(writerTransport{writer}, lines, exited)
+	}()
+
+	// waiting for the process closes its input before we learn it exited
+	writer.Close()
+	go func() {
+		lines <- "status"
+		close(exited)
+		lines <- "say bye"
+		close(lines)
+	}()
+
+	err = <-passed
+}
+
+func TestRunFinalStateDrainsOutput(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	// the process prints a lot more than a pipe holds after the runner stopped
+	cmd := exec.Command("sh", "-c", `
+		echo "Match ended"
+		i=0
+		while [ $i -lt 20000 ]; do
+			echo "still running $i, filling up the pipe"
+			i=$((i+1))
+		done
+		exit 2
+	`)
+
+	config := &runner.Config{
+		InitialState: "playing",
+		States: runner.StateConfigMap{
+			"playing": runner.StateConfig{
+				Events: runner.EventConfigList{
+					runner.LiteralEventConfig{
+						Value:     "Match ended",
+						NextState: "completed",
+					},
+				},
+			},
+			"completed": runner.StateConfig{
+				Final:    true,
+				ExitCode: 3,
+			},
+		},
+	}
+
+	exit, err := RunWithRunner(cmd, config, false, Options{})
+	if err != nil {
+		return
+	}
+	assert.Equal(test, 3, exit)
+}
+
+func TestRunInitialEnterActions(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	cmd := exec.Command("sh", "-c", `read line; echo "got $line"`)
+
+	config := &runner.Config{
+		InitialState: "lobby",
+		States: runner.StateConfigMap{
+			"lobby": runner.StateConfig{
+				OnEnter: runner.TransitionConfigList{
+					runner.CommandTransitionConfig{
+						Command: "hello",
+					},
+				},
+				Events: runner.EventConfigList{
+					runner.LiteralEventConfig{
+						Value:     "got hello",
+						NextState: "done",
+					},
+				},
+			},
+			"done": runner.StateConfig{
+				Final:    true,
+				ExitCode: 4,
+			},
+		},
+	}
+
+	exit, err := RunWithRunner(cmd, config, false, Options{})
+	if err != nil {
+		return
+	}
+	assert.Equal(test, 4, exit)
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+// defaultShutdownTimeout is used when a shutdown action has no timeout
+const defaultShutdownTimeout = time.Second * 10
+
+/*
+shutdown stops the process, first gracefully, then by terminating and
+finally by killing the process group. Returns the stage that stopped the
+process.
+*/
+func shutdown(
+	cmd *exec.Cmd,
+	action runner.ShutdownAction,
+	inputLines chan<- string,
+	signals chan<- os.Signal,
+	exited <-chan struct{},
+) (
+	stage string,
+) {
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	stage = "exit"
+	select {
+	case <-exited:
+		// nobody is reading commands anymore
+		return
+	default:
+	}
+
+	if action.Command != "" {
+		stage = "command"
+		inputLines <- action.Command
+	}
+	if action.Signal != nil {
+		stage = "signal"
+		signals <- action.Signal
+	}
+	if waitExited(exited, timeout) {
+		return
+	}
+
+	stage = "SIGTERM"
+	signalGroup(cmd.Process, syscall.SIGTERM)
+	if waitExited(exited, timeout) {
+		return
+	}
+
+	stage = "SIGKILL"
+	signalGroup(cmd.Process, syscall.SIGKILL)
+	<-exited
+
+	return
+}
+
+// waitExited waits at most timeout for the process to exit
+func waitExited(
+	exited <-chan struct{},
+	timeout time.Duration,
+) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+/*
+signalGroup sends a signal to the process group of a process, if the process
+is not a group leader the signal is sent to the process only
+*/
+func signalGroup(
+	process *os.Process,
+	signal syscall.Signal,
+) {
+	/*
+		ignore errors, possible errors include the process to be stopped
+		already.
+	*/
+	if pgid, err := syscall.Getpgid(process.Pid); err == nil && pgid == process.Pid {
+		_ = syscall.Kill(-pgid, signal)
+		return
+	}
+	_ = process.Signal(signal)
+}
+
+// reportShutdown tells which stage stopped the process
+func reportShutdown(
+	stage string,
+) {
+	fmt.Fprintf(os.Stderr, "igniter-shell: process stopped after %s\n", stage)
+}
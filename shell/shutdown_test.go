@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownSignal(test *testing.T) {
+	stage := runShutdown(test, "sleep 10", runner.ShutdownAction{
+		Signal:  syscall.SIGHUP,
+		Timeout: time.Second,
+	})
+	assert.Equal(test, "signal", stage)
+}
+
+func TestShutdownEscalate(test *testing.T) {
+	stage := runShutdown(test, `trap "" INT TERM; sleep 10`, runner.ShutdownAction{
+		Signal:  syscall.SIGINT,
+		Timeout: time.Millisecond * 100,
+	})
+	assert.Equal(test, "SIGKILL", stage)
+}
+
+func runShutdown(
+	test *testing.T,
+	script string,
+	action runner.ShutdownAction,
+) (
+	stage string,
+) {
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := cmd.Start()
+	if !assert.NoError(test, err) {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	go passSignals(cmd.Process, signals, false)
+	defer close(signals)
+
+	// give the script some time to setup traps
+	time.Sleep(time.Millisecond * 100)
+
+	exited := make(chan struct{})
+	go func() {
+		_, _ = waitCommand(cmd)
+		close(exited)
+	}()
+
+	stage = shutdown(cmd, action, nil, signals, exited)
+	return
+}
+
+func TestShutdownExited(test *testing.T) {
+	exited := make(chan struct{})
+	close(exited)
+
+	// the command is not sent, nobody reads it
+	stage := shutdown(nil, runner.ShutdownAction{
+		Command: "quit",
+	}, nil, nil, exited)
+	assert.Equal(test, "exit", stage)
+}
@@ -0,0 +1,171 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+// signalPolicy holds what to do with every incoming signal
+type signalPolicy map[os.Signal]signalRoute
+
+/*
+signalRoute is what happens with a single incoming signal, exactly one of the
+fields is set, if none is set the signal is ignored.
+*/
+type signalRoute struct {
+	signal os.Signal
+	line   string
+	call   func()
+}
+
+/*
+makeSignalPolicy creates a policy from config. Signals that are not
+configured are forwarded as-is, except for signals that only make sense for
+the shell itself.
+*/
+func makeSignalPolicy(
+	configs SignalConfigMap,
+	resize func(),
+) (
+	policy signalPolicy,
+	err error,
+) {
+	policy = signalPolicy{
+		// we are the parent, not the process
+		syscall.SIGCHLD: signalRoute{},
+		// used by the go runtime for preemption
+		syscall.SIGURG: signalRoute{},
+		// the process has no terminal of its own
+		syscall.SIGWINCH: signalRoute{},
+	}
+	if resize != nil {
+		// the kernel signals the process when the pty is resized
+		policy[syscall.SIGWINCH] = signalRoute{call: resize}
+	}
+
+	for name, config := range configs {
+		var incoming os.Signal
+		var route signalRoute
+		incoming, route, err = makeSignalRoute(name, config)
+		if err != nil {
+			return
+		}
+		policy[incoming] = route
+	}
+
+	return
+}
+
+// makeSignalRoute creates the route for a single signal from config
+func makeSignalRoute(
+	name string,
+	config SignalConfig,
+) (
+	incoming os.Signal,
+	route signalRoute,
+	err error,
+) {
+	incoming = runner.ParseSignal(name)
+	if incoming == nil {
+		err = fmt.Errorf("unknown signal %q", name)
+		return
+	}
+
+	switch config.Action {
+
+	case "forward", "":
+		route.signal = incoming
+
+	case "translate":
+		route.signal = runner.ParseSignal(config.Signal)
+		if route.signal == nil {
+			err = fmt.Errorf("unknown signal %q to translate %s to", config.Signal, name)
+			return
+		}
+
+	case "event":
+		route.line = config.Line
+		if route.line == "" {
+			route.line = name
+		}
+
+	case "ignore":
+
+	default:
+		err = fmt.Errorf("unknown action %q for signal %s", config.Action, name)
+		return
+
+	}
+
+	return
+}
+
+/*
+route returns what to do with an incoming signal, signals that are not in
+the policy are forwarded
+*/
+func (policy signalPolicy) route(
+	incoming os.Signal,
+) signalRoute {
+	route, ok := policy[incoming]
+	if !ok {
+		route.signal = incoming
+	}
+	return route
+}
+
+/*
+routeSignals routes incoming signals according to the policy until the
+returned function is called
+*/
+func routeSignals(
+	incoming chan os.Signal,
+	policy signalPolicy,
+	signals chan<- os.Signal,
+	injectLines chan<- string,
+) (
+	stop func(),
+) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-done:
+				return
+
+			case incomingSignal := <-incoming:
+				route := policy.route(incomingSignal)
+				switch {
+				case route.signal != nil:
+					signals <- route.signal
+
+				case route.line != "":
+					select {
+					case injectLines <- route.line:
+					case <-done:
+						return
+					}
+
+				case route.call != nil:
+					route.call()
+				}
+			}
+		}
+	}()
+
+	stop = func() {
+		signal.Stop(incoming)
+		close(done)
+		<-stopped
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalPolicy(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	policy, err := makeSignalPolicy(SignalConfigMap{
+		"SIGTERM": SignalConfig{Action: "event"},
+		"SIGINT":  SignalConfig{Action: "translate", Signal: "SIGQUIT"},
+		"SIGHUP":  SignalConfig{Action: "ignore"},
+		"SIGUSR2": SignalConfig{Action: "event", Line: "usr2 received"},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	incoming := make(chan os.Signal)
+	signals := make(chan os.Signal, 10)
+	injectLines := make(chan string, 10)
+
+	stop := routeSignals(incoming, policy, signals, injectLines)
+
+	incoming <- syscall.SIGTERM
+	incoming <- syscall.SIGINT
+	incoming <- syscall.SIGHUP
+	incoming <- syscall.SIGCHLD
+	incoming <- syscall.SIGWINCH
+	incoming <- syscall.SIGUSR1
+	incoming <- syscall.SIGUSR2
+	incoming <- syscall.SIGUSR1
+
+	stop()
+	close(signals)
+	close(injectLines)
+
+	var routedSignals []os.Signal
+	for signal := range signals {
+		routedSignals = append(routedSignals, signal)
+	}
+	var routedLines []string
+	for line := range injectLines {
+		routedLines = append(routedLines, line)
+	}
+
+	assert.Equal(test, []os.Signal{syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR1}, routedSignals)
+	assert.Equal(test, []string{"SIGTERM", "usr2 received"}, routedLines)
+}
+
+func TestInvalidSignalPolicy(test *testing.T) {
+	var err error
+
+	_, err = makeSignalPolicy(SignalConfigMap{
+		"SIGNOPE": SignalConfig{},
+	}, nil)
+	assert.Error(test, err)
+
+	_, err = makeSignalPolicy(SignalConfigMap{
+		"SIGTERM": SignalConfig{Action: "translate", Signal: "SIGNOPE"},
+	}, nil)
+	assert.Error(test, err)
+
+	_, err = makeSignalPolicy(SignalConfigMap{
+		"SIGTERM": SignalConfig{Action: "explode"},
+	}, nil)
+	assert.Error(test, err)
+}
@@ -0,0 +1,42 @@
+package shell
+
+/*
+startSources starts reading lines from every source until the returned stop
+function is called, the lines of all sources are merged
+*/
+func startSources(
+	configs SourceConfigList,
+) (
+	lines <-chan string,
+	stop func(),
+	err error,
+) {
+	done := make(chan struct{})
+	var sourceLines []<-chan string
+
+	for _, configUnknown := range configs {
+		switch config := configUnknown.(type) {
+		case FileSourceConfig:
+			sourceLines = append(
+				sourceLines,
+				tailFiles(config.Path, tailInterval, done),
+			)
+
+		case UDPSourceConfig:
+			var udpLines <-chan string
+			udpLines, err = listenLogs(config.Address, config.Secret, done)
+			if err != nil {
+				close(done)
+				return
+			}
+			sourceLines = append(sourceLines, udpLines)
+		}
+	}
+
+	lines = mergeLines(sourceLines...)
+	stop = func() {
+		close(done)
+	}
+
+	return
+}
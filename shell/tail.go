@@ -0,0 +1,234 @@
+package shell
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/utils"
+)
+
+// tailInterval is the time between two checks for new lines and files
+const tailInterval = time.Millisecond * 250
+
+/*
+tailFiles reads lines that are appended to the files that match pattern
+until done is closed. The pattern is matched again on every check, so files
+that do not exist yet are picked up when they appear. A file that is
+replaced (rotated) is read to the end before the new file is read from the
+start, a file that is truncated is read from the start again.
+*/
+func tailFiles(
+	pattern string,
+	interval time.Duration,
+	done <-chan struct{},
+) <-chan string {
+	lines := make(chan string, 10000)
+
+	go func() {
+		defer close(lines)
+
+		emit := func(line string) bool {
+			line = strings.TrimSpace(utils.StripSpecial(line))
+			if line == "" {
+				return true
+			}
+			select {
+			case lines <- line:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		tails := make(map[string]*fileTail)
+		defer func() {
+			for _, tail := range tails {
+				tail.close()
+			}
+		}()
+
+		// files that exist before we start hold old lines
+		fromEnd := true
+		for {
+			if !pollFiles(pattern, tails, fromEnd, emit) {
+				return
+			}
+			fromEnd = false
+
+			select {
+			case <-done:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return lines
+}
+
+/*
+pollFiles matches the pattern, opens new files, closes files that are gone or
+replaced and reads new lines from all files. Returns false if emit does.
+*/
+func pollFiles(
+	pattern string,
+	tails map[string]*fileTail,
+	fromEnd bool,
+	emit func(string) bool,
+) bool {
+	// a bad pattern never matches, validate reports it
+	matches, _ := filepath.Glob(pattern)
+	sort.Strings(matches)
+
+	matched := make(map[string]bool)
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		matched[path] = true
+
+		tail, ok := tails[path]
+		if ok && !os.SameFile(tail.info, info) {
+			// rotated, finish the old file first
+			ok = tail.read(emit) && tail.flush(emit)
+			tail.close()
+			delete(tails, path)
+			if !ok {
+				return false
+			}
+		}
+		if _, ok := tails[path]; !ok {
+			tail, err = openFileTail(path, fromEnd)
+			if err != nil {
+				continue
+			}
+			tails[path] = tail
+		}
+	}
+
+	for path, tail := range tails {
+		if matched[path] {
+			continue
+		}
+		ok := tail.read(emit) && tail.flush(emit)
+		tail.close()
+		delete(tails, path)
+		if !ok {
+			return false
+		}
+	}
+
+	for _, path := range matches {
+		tail, ok := tails[path]
+		if !ok {
+			continue
+		}
+		if !tail.read(emit) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fileTail is a file that is being tailed
+type fileTail struct {
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+}
+
+func openFileTail(
+	path string,
+	fromEnd bool,
+) (
+	tail *fileTail,
+	err error,
+) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	tail = &fileTail{
+		file: file,
+		info: info,
+	}
+	if fromEnd {
+		tail.offset, err = file.Seek(0, io.SeekEnd)
+		if err != nil {
+			file.Close()
+			return
+		}
+	}
+
+	return
+}
+
+/*
+read emits all complete lines that were written since the last read, an
+incomplete last line is kept until it is complete. Returns false if emit
+does.
+*/
+func (tail *fileTail) read(
+	emit func(string) bool,
+) bool {
+	info, err := tail.file.Stat()
+	if err != nil {
+		return true
+	}
+	if info.Size() < tail.offset {
+		// truncated, start over
+		tail.offset = 0
+		tail.partial = nil
+	}
+
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := tail.file.ReadAt(buffer, tail.offset)
+		tail.offset += int64(n)
+		data := append(tail.partial, buffer[:n]...)
+
+		for {
+			index := bytes.IndexByte(data, '\n')
+			if index < 0 {
+				break
+			}
+			if !emit(string(data[:index])) {
+				return false
+			}
+			data = data[index+1:]
+		}
+		tail.partial = append([]byte(nil), data...)
+
+		if err != nil || n == 0 {
+			return true
+		}
+	}
+}
+
+// flush emits the incomplete last line, if any
+func (tail *fileTail) flush(
+	emit func(string) bool,
+) bool {
+	line := string(tail.partial)
+	tail.partial = nil
+	return emit(line)
+}
+
+func (tail *fileTail) close() {
+	tail.file.Close()
+}
@@ -0,0 +1,112 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailFiles(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	dir, err := ioutil.TempDir("", "tail")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.log")
+	err = ioutil.WriteFile(path, []byte("old line\n"), 0644)
+	if err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	lines := tailFiles(
+		filepath.Join(dir, "*.log"),
+		time.Millisecond*10,
+		done,
+	)
+	defer close(done)
+	time.Sleep(time.Millisecond * 50)
+
+	// existing lines are skipped, incomplete lines wait
+	err = appendFile(path, "first\nsec")
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "first", receiveLine(lines))
+	err = appendFile(path, "ond\n")
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "second", receiveLine(lines))
+
+	// truncated
+	err = ioutil.WriteFile(path, []byte("x\n"), 0644)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "x", receiveLine(lines))
+
+	// rotated
+	err = appendFile(path, "before rotate\n")
+	if err != nil {
+		return
+	}
+	err = os.Rename(path, path+".1")
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(path, []byte("after rotate\n"), 0644)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "before rotate", receiveLine(lines))
+	assert.Equal(test, "after rotate", receiveLine(lines))
+
+	// new file that matches the pattern
+	err = ioutil.WriteFile(filepath.Join(dir, "other.log"), []byte("new file\n"), 0644)
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "new file", receiveLine(lines))
+}
+
+func appendFile(
+	path string,
+	content string,
+) (
+	err error,
+) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func receiveLine(
+	lines <-chan string,
+) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(time.Second * 5):
+		return "<timeout>"
+	}
+}
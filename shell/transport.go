@@ -0,0 +1,41 @@
+package shell
+
+import (
+	"io"
+)
+
+/*
+commandTransport sends commands to the process
+*/
+type commandTransport interface {
+	sendCommand(command string) error
+	close() error
+}
+
+/*
+writerTransport sends commands by writing them as lines to the stdin or the
+pty of the process
+*/
+type writerTransport struct {
+	writer io.Writer
+}
+
+func (transport writerTransport) sendCommand(
+	command string,
+) (
+	err error,
+) {
+	_, err = io.WriteString(transport.writer, command+"\n")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (transport writerTransport) close() (
+	err error,
+) {
+	// the writer is owned by the caller
+	return
+}
@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"github.com/Gameye/igniter-shell-go/utils"
+)
+
+// udpLogHeader starts every source engine log packet
+var udpLogHeader = []byte{0xff, 0xff, 0xff, 0xff}
+
+// packet types of source engine log packets
+const (
+	udpLogPlain  = 'R'
+	udpLogSecret = 'S'
+)
+
+/*
+listenLogs receives source engine log packets, as sent after logaddress_add,
+on address until done is closed. If secret is set, only packets with that
+secret (sv_logsecret) are accepted.
+*/
+func listenLogs(
+	address string,
+	secret string,
+	done <-chan struct{},
+) (
+	lines <-chan string,
+	err error,
+) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		<-done
+		conn.Close()
+	}()
+
+	packetLines := make(chan string, 10000)
+	go func() {
+		defer close(packetLines)
+
+		buffer := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			for _, line := range parseLogPacket(buffer[:n], secret) {
+				select {
+				case packetLines <- line:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	lines = packetLines
+	return
+}
+
+/*
+parseLogPacket returns the lines in a log packet without the packet header,
+packets that are not log packets or have the wrong secret have no lines
+*/
+func parseLogPacket(
+	packet []byte,
+	secret string,
+) (
+	lines []string,
+) {
+	if !bytes.HasPrefix(packet, udpLogHeader) || len(packet) < 5 {
+		return
+	}
+	packetType := packet[4]
+	body := string(packet[5:])
+
+	switch packetType {
+	case udpLogPlain:
+		if secret != "" {
+			return
+		}
+
+	case udpLogSecret:
+		// the secret is followed by the log line, that starts with "L "
+		index := strings.Index(body, "L ")
+		if index < 0 || body[:index] != secret {
+			return
+		}
+		body = body[index:]
+
+	default:
+		return
+	}
+
+	body = strings.TrimRight(body, "\x00")
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(utils.StripSpecial(line))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogPacket(test *testing.T) {
+	line := `L 04/07/2020 - 12:00:00: World triggered "Match_Start"`
+
+	assert.Equal(
+		test,
+		[]string{line},
+		parseLogPacket([]byte("\xff\xff\xff\xffR"+line+"\n\x00"), ""),
+	)
+	assert.Equal(
+		test,
+		[]string{line},
+		parseLogPacket([]byte("\xff\xff\xff\xffS1234"+line+"\n\x00"), "1234"),
+	)
+	assert.Empty(
+		test,
+		parseLogPacket([]byte("\xff\xff\xff\xffS4321"+line+"\n\x00"), "1234"),
+	)
+	assert.Empty(
+		test,
+		parseLogPacket([]byte("\xff\xff\xff\xffR"+line+"\n\x00"), "1234"),
+	)
+	assert.Empty(
+		test,
+		parseLogPacket([]byte(line), ""),
+	)
+}
+
+func TestListenLogs(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	// find a free port
+	probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	address := probe.LocalAddr().String()
+	probe.Close()
+
+	done := make(chan struct{})
+	lines, err := listenLogs(address, "", done)
+	if err != nil {
+		return
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("\xff\xff\xff\xffRL 04/07/2020 - 12:00:00: Log file started\n\x00"))
+	if err != nil {
+		return
+	}
+	assert.Equal(test, "L 04/07/2020 - 12:00:00: Log file started", receiveLine(lines))
+
+	close(done)
+	for range lines {
+	}
+}
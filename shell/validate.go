@@ -0,0 +1,131 @@
+package shell
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+/*
+Validate checks a config for problems, every problem has the path in the
+config where it was found
+*/
+func Validate(
+	config *Config,
+) (
+	errs []runner.ValidationError,
+) {
+	if config.Script == nil {
+		errs = append(errs, runner.ValidationError{
+			Path:    "script",
+			Message: "missing script",
+		})
+	} else {
+		for _, err := range runner.Validate(config.Script) {
+			err.Path = "script." + err.Path
+			errs = append(errs, err)
+		}
+	}
+
+	signalNames := make([]string, 0, len(config.Signals))
+	for name := range config.Signals {
+		signalNames = append(signalNames, name)
+	}
+	sort.Strings(signalNames)
+	for _, name := range signalNames {
+		_, _, err := makeSignalRoute(name, config.Signals[name])
+		if err != nil {
+			errs = append(errs, runner.ValidationError{
+				Path:    "signals." + name,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for index, file := range config.Files {
+		if file.Path == "" {
+			errs = append(errs, runner.ValidationError{
+				Path:    "files[" + strconv.Itoa(index) + "].path",
+				Message: "missing path",
+			})
+		}
+	}
+
+	for index, sourceConfigUnknown := range config.Sources {
+		path := "sources[" + strconv.Itoa(index) + "]"
+		switch sourceConfig := sourceConfigUnknown.(type) {
+		case FileSourceConfig:
+			if sourceConfig.Path == "" {
+				errs = append(errs, runner.ValidationError{
+					Path:    path + ".path",
+					Message: "missing path",
+				})
+			} else if _, err := filepath.Match(sourceConfig.Path, ""); err != nil {
+				errs = append(errs, runner.ValidationError{
+					Path:    path + ".path",
+					Message: "bad pattern " + strconv.Quote(sourceConfig.Path),
+				})
+			}
+
+		case UDPSourceConfig:
+			if sourceConfig.Address == "" {
+				errs = append(errs, runner.ValidationError{
+					Path:    path + ".address",
+					Message: "missing address",
+				})
+			}
+
+		case UnknownSourceConfig:
+			message := "unknown source type " + strconv.Quote(sourceConfig.Type)
+			if sourceConfig.Type == "" {
+				message = "missing source type"
+			}
+			errs = append(errs, runner.ValidationError{
+				Path:    path + ".type",
+				Message: message,
+			})
+		}
+	}
+
+	if config.Restart != nil {
+		if config.Restart.MaxRestarts < 0 {
+			errs = append(errs, runner.ValidationError{
+				Path:    "restart.maxRestarts",
+				Message: "should not be negative",
+			})
+		}
+		if config.Restart.Backoff < 0 {
+			errs = append(errs, runner.ValidationError{
+				Path:    "restart.backoff",
+				Message: "should not be negative",
+			})
+		}
+		state := config.Restart.ResumeState
+		if state != "" && config.Script != nil {
+			if _, ok := config.Script.States[state]; !ok {
+				errs = append(errs, runner.ValidationError{
+					Path:    "restart.resumeState",
+					Message: "unknown state " + strconv.Quote(state),
+				})
+			}
+		}
+	}
+
+	if config.Result != nil && config.Result.Path == "" {
+		errs = append(errs, runner.ValidationError{
+			Path:    "result.path",
+			Message: "missing path",
+		})
+	}
+
+	if config.RCON != nil && config.RCON.Address == "" {
+		errs = append(errs, runner.ValidationError{
+			Path:    "rcon.address",
+			Message: "missing address",
+		})
+	}
+
+	return
+}
@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+)
+
+// defaultHTTPTimeout is used when an http action has no timeout
+const defaultHTTPTimeout = time.Second * 10
+
+// httpRetryInterval is the time between two attempts of an http action
+const httpRetryInterval = time.Second
+
+/*
+postHTTP performs an http action, a request that fails or gets a response
+that is not a success is tried again until there are no retries left
+*/
+func postHTTP(
+	action runner.HTTPAction,
+) (
+	err error,
+) {
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	for attempt := 0; ; attempt++ {
+		err = postHTTPOnce(client, action)
+		if err == nil || attempt >= action.Retries {
+			return
+		}
+		time.Sleep(httpRetryInterval)
+	}
+}
+
+func postHTTPOnce(
+	client *http.Client,
+	action runner.HTTPAction,
+) (
+	err error,
+) {
+	request, err := http.NewRequest(
+		http.MethodPost,
+		action.URL,
+		bytes.NewReader(action.Body),
+	)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	// read the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		err = fmt.Errorf("unexpected status %s", response.Status)
+		return
+	}
+
+	return
+}
+
+// reportHTTP reports an http action that failed
+func reportHTTP(
+	action runner.HTTPAction,
+	err error,
+) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "igniter-shell: could not post to %s: %v\n", action.URL, err)
+}
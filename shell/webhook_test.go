@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gameye/igniter-shell-go/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostHTTP(test *testing.T) {
+	var err error
+	defer func() {
+		assert.NoError(test, err)
+	}()
+
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(
+		writer http.ResponseWriter,
+		request *http.Request,
+	) {
+		body, _ := ioutil.ReadAll(request.Body)
+		assert.Equal(test, http.MethodPost, request.Method)
+		assert.Equal(test, "application/json", request.Header.Get("Content-Type"))
+
+		// the first attempt fails
+		if len(bodies) == 0 {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	err = postHTTP(runner.HTTPAction{
+		URL:     server.URL + "/matches/42",
+		Body:    []byte(`{"state":"playing"}`),
+		Retries: 1,
+	})
+	if err != nil {
+		return
+	}
+
+	assert.Len(test, bodies, 2)
+	assert.Equal(test, `{"state":"playing"}`, <-bodies)
+}
+
+func TestPostHTTPFails(test *testing.T) {
+	attempts := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(
+		writer http.ResponseWriter,
+		request *http.Request,
+	) {
+		attempts <- struct{}{}
+		time.Sleep(time.Millisecond * 200)
+	}))
+	defer server.Close()
+
+	// without retries there is a single attempt
+	err := postHTTP(runner.HTTPAction{
+		URL:     server.URL,
+		Timeout: time.Millisecond * 50,
+	})
+	assert.Error(test, err)
+	assert.Len(test, attempts, 1)
+
+	err = postHTTP(runner.HTTPAction{
+		URL: server.URL + "/%zz",
+	})
+	assert.Error(test, err)
+}
+
+func TestPerformHTTPAction(test *testing.T) {
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(
+		writer http.ResponseWriter,
+		request *http.Request,
+	) {
+		<-received
+	}))
+	defer server.Close()
+
+	actions := make(chan runner.Action, 2)
+	inputLines := make(chan string, 1)
+	performed := make(chan struct{})
+	go func() {
+		defer close(performed)
+		performActions(nil, actions, inputLines, nil, nil, nil, Options{})
+	}()
+
+	// the request does not hold up the command that follows it
+	actions <- runner.HTTPAction{URL: server.URL}
+	actions <- runner.CommandAction{Command: "say playing"}
+	assert.Equal(test, "say playing", <-inputLines)
+
+	// the pending request is waited for
+	close(actions)
+	select {
+	case <-performed:
+		test.Error("actions performed before the request ended")
+	case <-time.After(time.Millisecond * 100):
+	}
+	close(received)
+	<-performed
+}